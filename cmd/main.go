@@ -1,39 +1,541 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/timtosi/mcrawler/internal"
+	"github.com/timtosi/mcrawler/internal/cache"
+	"github.com/timtosi/mcrawler/internal/checkpoint"
+	"github.com/timtosi/mcrawler/internal/cluster"
+	"github.com/timtosi/mcrawler/internal/control"
 	"github.com/timtosi/mcrawler/internal/crawler"
 	"github.com/timtosi/mcrawler/internal/domain"
 	"github.com/timtosi/mcrawler/internal/extractor"
 	"github.com/timtosi/mcrawler/internal/mapper"
+	"github.com/timtosi/mcrawler/internal/metrics"
+	"github.com/timtosi/mcrawler/internal/sitemap"
 )
 
+// notifyContext returns a `context.Context` cancelled on the first `SIGINT`
+// or `SIGTERM` received. Any further signal terminates the program.
+func notifyContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-sigs:
+			log.Printf("%v received, stopping crawl", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(sigs)
+	}()
+	return ctx, cancel
+}
+
+// hostPolicies is a named type implementing the `flag.Value` interface in order
+// to collect every `-host-policy` flag given.
+type hostPolicies []func(*internal.Politeness)
+
+// String implements the `flag.Value` interface.
+func (hp *hostPolicies) String() string { return "" }
+
+// Set implements the `flag.Value` interface.
+func (hp *hostPolicies) Set(s string) error {
+	host, policy, err := internal.ParseHostPolicy(s)
+	if err != nil {
+		return err
+	}
+	*hp = append(*hp, internal.WithHostPolicy(host, policy))
+	return nil
+}
+
+// repeatable is a named type implementing the `flag.Value` interface in order
+// to collect every value given to a repeatable flag.
+type repeatable []string
+
+// String implements the `flag.Value` interface.
+func (r *repeatable) String() string { return "" }
+
+// Set implements the `flag.Value` interface.
+func (r *repeatable) Set(s string) error {
+	*r = append(*r, s)
+	return nil
+}
+
+// splitHost splits `s`, formatted as `host=value`, into its host and value.
+func splitHost(s string) (string, string, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 {
+		return "", "", fmt.Errorf("invalid value %q: host= expected", s)
+	}
+	return parts[0], parts[1], nil
+}
+
+// splitHeader splits `s`, formatted as `Name: value`, into its name and
+// value.
+func splitHeader(s string) (string, string, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || len(strings.TrimSpace(parts[0])) == 0 {
+		return "", "", fmt.Errorf("invalid header %q: Name: value expected", s)
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), nil
+}
+
+// identityOpts returns the `*internal.Worker` options adding `headers`,
+// formatted as `Name: value`, to every request and `hostHeaders`, formatted
+// as `host=Name: value`, to the requests sent to a given host. Each of
+// `basicAuths`, formatted as `host=user:password`, and `bearerTokens`,
+// formatted as `host=token`, authenticates the requests sent to its host.
+func identityOpts(headers, hostHeaders, basicAuths, bearerTokens []string) ([]func(*internal.Worker), error) {
+	var opts []func(*internal.Worker)
+	for _, h := range headers {
+		key, value, err := splitHeader(h)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, internal.WithHeader(key, value))
+	}
+	for _, h := range hostHeaders {
+		host, header, err := splitHost(h)
+		if err != nil {
+			return nil, err
+		}
+		key, value, err := splitHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, internal.WithHostHeader(host, key, value))
+	}
+	for _, a := range basicAuths {
+		host, credentials, err := splitHost(a)
+		if err != nil {
+			return nil, err
+		}
+		parts := strings.SplitN(credentials, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid credentials for %s: user:password expected", host)
+		}
+		opts = append(opts, internal.WithBasicAuth(host, parts[0], parts[1]))
+	}
+	for _, b := range bearerTokens {
+		host, token, err := splitHost(b)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, internal.WithBearerToken(host, token))
+	}
+	return opts, nil
+}
+
+// proxyOpts returns the `*internal.Worker` options sending requests through
+// the proxy located at `proxy`, if any, and through the proxies of
+// `hostProxies`, formatted as `host=URL`, for the requests sent to a given
+// host. Requests to a host whose URL is `direct` are sent directly.
+func proxyOpts(proxy string, hostProxies []string) ([]func(*internal.Worker), error) {
+	parse := func(s string) (*url.URL, error) {
+		if s == "direct" {
+			return nil, nil
+		}
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+			return u, nil
+		}
+		return nil, fmt.Errorf("invalid proxy %q: http, https or socks5 scheme expected", s)
+	}
+
+	var opts []func(*internal.Worker)
+	if len(proxy) != 0 {
+		u, err := parse(proxy)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, internal.WithProxy(u))
+	}
+	for _, hp := range hostProxies {
+		host, value, err := splitHost(hp)
+		if err != nil {
+			return nil, err
+		}
+		u, err := parse(value)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, internal.WithHostProxy(host, u))
+	}
+	return opts, nil
+}
+
+// owns returns `true` if the host of `link` is owned by `n` or if the crawl
+// is not shared between several processes.
+func owns(n *cluster.Node, link string) bool {
+	return n == nil || n.Owns(link)
+}
+
+// serveNode serves the `http.Handler` of `n`, through which `c` receives
+// `*domain.Target`s, at the address of its own peer. It returns the
+// `*http.Server` to close once the crawl is over.
+func serveNode(n *cluster.Node, c *crawler.Crawler, self string) *http.Server {
+	u, err := url.Parse(self)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return serve(n.Handler(c), u.Host)
+}
+
+// serveMetrics serves `m` at the `/metrics` path of `addr`. It returns the
+// `*http.Server` to close once the crawl is over.
+func serveMetrics(m *metrics.Metrics, addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	return serve(mux, addr)
+}
+
+// serve serves `h` at `addr` in a new goroutine. It returns the `*http.Server`
+// to close once the crawl is over.
+func serve(h http.Handler, addr string) *http.Server {
+	srv := &http.Server{Addr: addr, Handler: h}
+	go func() {
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	return srv
+}
+
+// seedSitemaps pushes in `fr` every page found in the sitemaps located at
+// `sitemapURLs` and, if `fromRobots` is set, in the sitemaps listed by the
+// robots.txt files of `baseURLs` according to `r`. Pages whose host is not
+// owned by `n` are skipped.
+//
+// NOTE: Sitemaps are fetched with the `http.Client` of `w` without checking
+// their redirects, so that the URLs they are redirected to are not seen as
+// crawled.
+func seedSitemaps(ctx context.Context, fr crawler.Frontier, n *cluster.Node, w *internal.Worker, r *internal.Robots, baseURLs []string, fromRobots bool, sitemapURLs []string) {
+	for _, baseURL := range baseURLs {
+		if !fromRobots {
+			break
+		}
+
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if rules := r.Rules(ctx, u); rules != nil {
+			sitemapURLs = append(sitemapURLs, rules.Sitemaps...)
+		}
+	}
+
+	urls, err := sitemap.Fetch(internal.WithUncheckedRedirects(ctx), &w.Client, sitemapURLs...)
+	if err != nil {
+		log.Print(err)
+	}
+	seeded := 0
+	for _, u := range urls {
+		if owns(n, u) {
+			fr.Push(domain.NewTarget(u))
+			seeded++
+		}
+	}
+	log.Printf("seeded %d pages from %d sitemaps", seeded, len(sitemapURLs))
+}
+
 func main() {
-	if len(os.Args[1]) == 0 {
-		log.Fatal(`usage: ./mcrawler <BASE_URL>`)
+	var policies hostPolicies
+
+	maxDepth := flag.Int("max-depth", -1, "maximum number of links followed from a <BASE_URL>, no limit if negative")
+	maxPages := flag.Int("max-pages", 0, "maximum number of pages fetched, no limit if 0")
+	maxBytes := flag.Int64("max-bytes", 0, "maximum number of bytes downloaded, no limit if 0")
+	maxDuration := flag.Duration("max-duration", 0, "maximum duration of the crawl, no limit if 0")
+	checkpointDir := flag.String("checkpoint", "", "directory where the state of the crawl is saved, none if empty")
+	checkpointEvery := flag.Duration("checkpoint-interval", time.Minute, "period between two saves of the state of the crawl")
+	resumeDir := flag.String("resume", "", "directory of a saved state to resume the crawl from, none if empty")
+	cacheDir := flag.String("cache", "", "directory where pages are kept to be fetched again with conditional requests by the next crawl, none if empty")
+	successStatus := flag.String("success-status", "2xx", "comma-separated status codes, ranges (200-299) or classes (2xx) of the pages that are mapped and whose links are followed")
+	maxRedirects := flag.Int("max-redirects", 10, "maximum number of redirects followed when fetching a page")
+	retries := flag.Int("retries", 2, "number of times a page is fetched again after a network error, a 429 or a 5xx response")
+	retryDelay := flag.Duration("retry-delay", time.Second, "delay before the first retry of a page, doubled for each following one")
+	retryMaxDelay := flag.Duration("retry-max-delay", 30*time.Second, "maximum delay before a retry, including the ones asked with Retry-After, no limit if 0")
+	maxBodySize := flag.Int64("max-body-size", 10<<20, "maximum number of bytes read from a page, larger pages are truncated, no limit if 0")
+	contentTypes := flag.String("content-types", strings.Join(internal.HTMLContentTypes, ","), "comma-separated media types (text/html) or types (image/*) of the pages downloaded, others are skipped once their headers are received, all if empty")
+	headNonHTML := flag.Bool("head-non-html", false, "send HEAD requests instead of GET for resources whose extension is known not to be HTML, e.g. images")
+	checkAssets := flag.Bool("check-assets", false, "only check that assets (images, PDFs, archives...) can be fetched with HEAD requests instead of downloading them")
+	concurrency := flag.Int("concurrency", internal.DefaultConcurrency, "maximum number of pages fetched at the same time, no limit if 0")
+	delay := flag.Duration("delay", 0, "minimum time between two requests to the same host")
+	hostMaxConns := flag.Int("host-max-conns", 0, "maximum number of requests in flight to the same host, no limit if 0")
+	rate := flag.Float64("rate", 0, "maximum number of requests per second to the same host, no limit if 0")
+	burst := flag.Int("burst", 1, "number of requests allowed in a burst when -rate is set")
+	flag.Var(&policies, "host-policy", "politeness for a given host, e.g. host=example.com,delay=1s,max-conns=2,rate=0.5,burst=1 (repeatable)")
+	ignoreRobots := flag.Bool("ignore-robots", false, "crawl pages disallowed by robots.txt files")
+	userAgent := flag.String("user-agent", "mcrawler", "User-Agent header sent with every request")
+	var headers, hostHeaders, basicAuths, bearerTokens repeatable
+	flag.Var(&headers, "header", "header sent with every request, e.g. 'X-Env: staging' (repeatable)")
+	flag.Var(&hostHeaders, "host-header", "header sent with the requests to a given host, e.g. 'staging.example.com=X-Env: staging' (repeatable)")
+	flag.Var(&basicAuths, "basic-auth", "HTTP Basic credentials only sent to a given host, e.g. staging.example.com=user:password (repeatable)")
+	flag.Var(&bearerTokens, "bearer-token", "bearer token only sent to a given host, e.g. staging.example.com=token (repeatable)")
+	proxy := flag.String("proxy", "", "URL of the http, https or socks5 proxy every request is sent through, or direct, HTTP_PROXY, HTTPS_PROXY and NO_PROXY are used if empty")
+	var hostProxies repeatable
+	flag.Var(&hostProxies, "host-proxy", "proxy the requests to a given host are sent through, e.g. intranet.example.com=socks5://localhost:1080 or example.com=direct (repeatable)")
+	cookies := flag.Bool("cookies", false, "keep the cookies received and send them back for the rest of the crawl")
+	robotsAgent := flag.String("robots-agent", "mcrawler", "user-agent token whose robots.txt rules are followed")
+	sitemapURL := flag.String("sitemap", "", "URL of a sitemap whose pages are crawled as well, none if empty")
+	sitemapRobots := flag.Bool("sitemap-robots", false, "crawl the pages of the sitemaps listed in the robots.txt files of <BASE_URL>s as well")
+	seedFile := flag.String("seeds", "", "file listing <BASE_URL>s to crawl, one per line, or - for the standard input")
+	clusterPeers := flag.String("cluster-peers", "", "comma-separated base URLs at which every process of a shared crawl listens, none if empty")
+	clusterNode := flag.Int("cluster-node", 0, "index in -cluster-peers of the address this process listens at")
+	metricsAddr := flag.String("metrics-addr", "", "address at which metrics are served in the Prometheus text format under /metrics, none if empty")
+	controlAddr := flag.String("control-addr", "", "address at which the API controlling the crawl is served, none if empty")
+	failOnPageErrors := flag.Bool("fail-on-page-errors", false, "exit with status 3 if any page could not be crawled")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: ./mcrawler [flags] <BASE_URL>...")
+		flag.PrintDefaults()
 	}
+	flag.Parse()
 
-	t := domain.NewTarget(os.Args[1])
-	m := mapper.NewMapper()
-	f, err := internal.NewFollower(t.BaseURL)
+	baseURLs := flag.Args()
+	if len(*seedFile) != 0 {
+		urls, err := readSeedFile(*seedFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		baseURLs = append(baseURLs, urls...)
+	}
+	if len(baseURLs) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := notifyContext()
+	defer cancel()
+
+	var n *cluster.Node
+	var peers []string
+	if len(*clusterPeers) != 0 {
+		var err error
+		peers = strings.Split(*clusterPeers, ",")
+		if n, err = cluster.NewNode(*clusterNode, peers); err != nil {
+			log.Fatal(err)
+		}
+		defer n.Close()
+	}
+
+	var seeds []*domain.Target
+	for _, baseURL := range baseURLs {
+		if owns(n, baseURL) {
+			seeds = append(seeds, domain.NewTarget(baseURL))
+		}
+	}
+	var pc *cache.Cache
+	var mapperOpts []func(*mapper.Mapper)
+	if len(*cacheDir) != 0 {
+		var err error
+		if pc, err = cache.Load(*cacheDir); err != nil {
+			log.Fatal(err)
+		}
+		mapperOpts = append(mapperOpts, mapper.WithLastModified(pc.LastModified))
+	}
+
+	a := internal.NewArchiver()
+	m := mapper.NewMapper(mapperOpts...)
+	fr := crawler.NewFIFOFrontier()
+	if len(*resumeDir) != 0 {
+		cp, err := checkpoint.Load(*resumeDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		a.Restore(cp.Seen)
+		for _, link := range cp.SiteMap {
+			m.Add(link)
+		}
+		for _, pending := range cp.Frontier {
+			fr.Push(pending)
+		}
+		if len(*checkpointDir) == 0 {
+			*checkpointDir = *resumeDir
+		}
+		log.Printf("resuming crawl with %d pending pages", fr.Len())
+	}
+
+	f, err := internal.NewFollower(baseURLs...)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if err := crawler.NewCrawler().Run(
-		t,
-		internal.NewArchiver(),
+	b := internal.NewBudget(
+		internal.WithMaxPages(*maxPages),
+		internal.WithMaxBytes(*maxBytes),
+		internal.WithMaxDuration(*maxDuration),
+	)
+
+	p := internal.NewPoliteness(
+		internal.HostPolicy{Delay: *delay, MaxConns: *hostMaxConns, Rate: *rate, Burst: *burst},
+		policies...,
+	)
+
+	status, err := internal.ParseStatusPolicy(*successStatus)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	workerOpts := []func(*internal.Worker){
+		internal.WithStatusPolicy(status),
+		internal.WithMaxRedirects(*maxRedirects),
+		internal.WithRedirectCheckers(f, a),
+		internal.WithRetry(internal.RetryPolicy{
+			MaxAttempts: *retries + 1,
+			BaseDelay:   *retryDelay,
+			MaxDelay:    *retryMaxDelay,
+		}),
+		internal.WithBudget(b),
+		internal.WithPoliteness(p),
+		internal.WithConcurrency(*concurrency),
+		internal.WithMaxBodySize(*maxBodySize),
+	}
+	if len(*contentTypes) != 0 {
+		workerOpts = append(workerOpts, internal.WithContentTypes(strings.Split(*contentTypes, ",")...))
+	}
+	if *headNonHTML {
+		workerOpts = append(workerOpts, internal.WithHeadNonHTML())
+	}
+	if *checkAssets {
+		workerOpts = append(workerOpts, internal.WithAssetCheck())
+	}
+	if pc != nil {
+		workerOpts = append(workerOpts, internal.WithCache(pc))
+	}
+	if len(*userAgent) != 0 {
+		workerOpts = append(workerOpts, internal.WithUserAgent(*userAgent))
+	}
+	if *cookies {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		workerOpts = append(workerOpts, internal.WithCookieJar(jar))
+	}
+	idOpts, err := identityOpts(headers, hostHeaders, basicAuths, bearerTokens)
+	if err != nil {
+		log.Fatal(err)
+	}
+	workerOpts = append(workerOpts, idOpts...)
+	pxOpts, err := proxyOpts(*proxy, hostProxies)
+	if err != nil {
+		log.Fatal(err)
+	}
+	workerOpts = append(workerOpts, pxOpts...)
+	w := internal.NewWorker(workerOpts...)
+
+	r := internal.NewRobots(w, internal.WithRobotsAgent(*robotsAgent))
+	if *sitemapRobots || len(*sitemapURL) != 0 {
+		var sitemapURLs []string
+		if len(*sitemapURL) != 0 {
+			sitemapURLs = append(sitemapURLs, *sitemapURL)
+		}
+		seedSitemaps(ctx, fr, n, w, r, baseURLs, *sitemapRobots, sitemapURLs)
+	}
+
+	var pipeline []internal.Pipe
+	if *maxDepth >= 0 {
+		pipeline = append(pipeline, internal.NewDepthLimiter(*maxDepth))
+	}
+	pipeline = append(pipeline, a, f)
+	if !*ignoreRobots {
+		pipeline = append(pipeline, r)
+	}
+	pipeline = append(
+		pipeline,
+		w,
 		m,
-		f,
-		internal.NewWorker(),
-		extractor.NewExtractor(extractor.GetImg, extractor.GetLinkNoFollow),
-	); err != nil {
+		internal.SkipAssets(),
+		extractor.NewExtractor([]extractor.CheckFunc{extractor.GetImg, extractor.GetLinkNoFollow}),
+	)
+	if n != nil {
+		pipeline = append(pipeline, n)
+	}
+
+	opts := []func(*crawler.Crawler){crawler.WithFrontier(fr), crawler.WithBudget(b)}
+	if len(*checkpointDir) != 0 {
+		opts = append(opts, crawler.WithCheckpoint(*checkpointDir, *checkpointEvery, a, m))
+	}
+
+	if n != nil {
+		opts = append(opts, crawler.WithTerminator(n))
+	}
+
+	var c *crawler.Crawler
+	var mt *metrics.Metrics
+	if len(*metricsAddr) != 0 {
+		mt = metrics.NewMetrics(metrics.WithGauge(
+			"mcrawler_frontier_length",
+			"Number of targets waiting in the frontier.",
+			func() float64 { return float64(c.FrontierLen()) },
+		))
+		opts = append(opts, crawler.WithObserver(mt))
+	}
+
+	var cs *control.Server
+	if len(*controlAddr) != 0 {
+		cs = control.NewServer()
+		opts = append(opts, crawler.WithObserver(cs))
+	}
+
+	c = crawler.NewCrawler(opts...)
+	if mt != nil {
+		srv := serveMetrics(mt, *metricsAddr)
+		defer srv.Close()
+	}
+	if cs != nil {
+		srv := serve(cs.Handler(c), *controlAddr)
+		defer srv.Close()
+	}
+	if n != nil {
+		srv := serveNode(n, c, peers[*clusterNode])
+		defer srv.Close()
+	}
+	switch err := c.Run(ctx, seeds, pipeline...); err {
+	case nil, context.Canceled:
+	case internal.ErrMaxPages, internal.ErrMaxBytes, internal.ErrMaxDuration:
+		log.Printf("crawl stopped: %v", err)
+	default:
 		log.Fatal(err)
 	}
 
 	m.Render()
+
+	if pc != nil {
+		if err := cache.Save(*cacheDir, pc); err != nil {
+			log.Print(err)
+		}
+		log.Printf("%d pages changed, %d unchanged since the previous crawl", len(pc.Changed(true)), len(pc.Changed(false)))
+	}
+
+	summary := c.ErrorSummary()
+	for _, e := range summary.Errors {
+		log.Print(e)
+	}
+	log.Print(summary)
 	log.Printf("shutdown")
+
+	if *failOnPageErrors && summary.Total != 0 {
+		os.Exit(3)
+	}
 }
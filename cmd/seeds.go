@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// readSeeds returns the URLs listed in `r`, one per line. Empty lines and
+// lines starting with `#` are ignored.
+func readSeeds(r io.Reader) ([]string, error) {
+	var seeds []string
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		seeds = append(seeds, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("readSeeds: %v", err)
+	}
+	return seeds, nil
+}
+
+// readSeedFile returns the URLs listed in the file located at `path`, or in
+// the standard input if `path` is `-`.
+func readSeedFile(path string) ([]string, error) {
+	if path == "-" {
+		return readSeeds(os.Stdin)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("readSeedFile: %v", err)
+	}
+	defer f.Close()
+
+	return readSeeds(f)
+}
@@ -0,0 +1,263 @@
+// Package crawltest provides synthetic websites to test crawling pipelines
+// without network access.
+//
+// A `crawltest.Site` describes the shape of a website. `crawltest.NewServer`
+// generates and serves it with an `*httptest.Server`, along with its expected
+// site map and link graph:
+//
+//	s := crawltest.NewServer(crawltest.Site{Pages: 20, FanOut: 3, Broken: 2})
+//	defer s.Close()
+//
+//	// Crawl s.Root() and compare the result with s.SiteMap() or s.Links().
+package crawltest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LinkKind is a named type representing the kind of a `crawltest.Link`.
+type LinkKind int
+
+const (
+	// LinkFollow is a link to a page of the site.
+	LinkFollow LinkKind = iota
+	// LinkNoFollow is a link marked with `rel="nofollow"` to a page only
+	// reachable through such links.
+	LinkNoFollow
+	// LinkBroken is a link to a page answering with `404 Not Found`.
+	LinkBroken
+	// LinkRedirect is a link to a page answering with `301 Moved Permanently`
+	// to the page given in `Link.Location`.
+	LinkRedirect
+	// LinkOffHost is a link to a page of another host, which never resolves.
+	LinkOffHost
+)
+
+// String implements the `fmt.Stringer` interface.
+func (k LinkKind) String() string {
+	switch k {
+	case LinkFollow:
+		return "follow"
+	case LinkNoFollow:
+		return "nofollow"
+	case LinkBroken:
+		return "broken"
+	case LinkRedirect:
+		return "redirect"
+	case LinkOffHost:
+		return "off-host"
+	}
+	return "unknown"
+}
+
+// Link is a `struct` representing a link found in the page located at `From`
+// to `To`. `Location` is the page `To` redirects to for a `LinkRedirect`.
+type Link struct {
+	From     string
+	To       string
+	Kind     LinkKind
+	Location string
+}
+
+// Site is a `struct` describing a synthetic website.
+//
+// The site is a tree of `Pages` pages, the root included, where every page
+// links to `FanOut` children and back to the root. No page is located more
+// than `Depth` links away from the root unless `Depth` is 0, in which case
+// the site may have fewer than `Pages` pages.
+//
+// `Broken`, `Redirects`, `NoFollow` and `OffHost` are numbers of links of the
+// matching `crawltest.LinkKind` added to the pages of the tree in turn. The
+// first `Slow` pages, starting with the root, answer after `SlowDelay`.
+type Site struct {
+	Pages  int
+	FanOut int
+	Depth  int
+
+	Broken    int
+	Redirects int
+	NoFollow  int
+	OffHost   int
+
+	Slow      int
+	SlowDelay time.Duration
+}
+
+// page is a `struct` representing a page served by a `*crawltest.Server`.
+type page struct {
+	links []Link
+	slow  bool
+}
+
+// Server is a `struct` serving a synthetic website described by a
+// `crawltest.Site`.
+type Server struct {
+	*httptest.Server
+	Site
+
+	tree      []string
+	links     []Link
+	pages     map[string]*page
+	redirects map[string]string
+	hits      map[string]int
+	mu        *sync.Mutex
+}
+
+// NewServer returns a new started `*crawltest.Server` serving the website
+// described by `site`. It should be closed once done with `Server.Close`.
+func NewServer(site Site) *Server {
+	s := &Server{
+		Site:      site,
+		pages:     make(map[string]*page),
+		redirects: make(map[string]string),
+		hits:      make(map[string]int),
+		mu:        &sync.Mutex{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	s.generate()
+	return s
+}
+
+// generate builds the pages and the link graph of `s`.
+func (s *Server) generate() {
+	s.tree = []string{"/"}
+	depths := []int{0}
+	s.pages["/"] = &page{}
+	for i := 0; i < len(s.tree); i++ {
+		for j := 0; j < s.FanOut && len(s.tree) < s.Pages; j++ {
+			if s.Depth > 0 && depths[i] >= s.Depth {
+				break
+			}
+
+			path := fmt.Sprintf("/page/%d", len(s.tree))
+			s.tree = append(s.tree, path)
+			depths = append(depths, depths[i]+1)
+			s.pages[path] = &page{}
+			s.link(s.tree[i], path, LinkFollow, "")
+			s.link(path, "/", LinkFollow, "")
+		}
+	}
+
+	for k := 0; k < s.Broken; k++ {
+		s.link(s.tree[k%len(s.tree)], fmt.Sprintf("/broken/%d", k), LinkBroken, "")
+	}
+	for k := 0; k < s.Redirects; k++ {
+		path := fmt.Sprintf("/redirect/%d", k)
+		s.redirects[path] = s.tree[k%len(s.tree)]
+		s.link(s.tree[k%len(s.tree)], path, LinkRedirect, s.tree[k%len(s.tree)])
+	}
+	for k := 0; k < s.NoFollow; k++ {
+		path := fmt.Sprintf("/hidden/%d", k)
+		s.pages[path] = &page{}
+		s.link(s.tree[k%len(s.tree)], path, LinkNoFollow, "")
+	}
+	for k := 0; k < s.OffHost; k++ {
+		s.link(s.tree[k%len(s.tree)], fmt.Sprintf("http://offhost-%d.invalid/", k), LinkOffHost, "")
+	}
+	for i := 0; i < s.Slow && i < len(s.tree); i++ {
+		s.pages[s.tree[i]].slow = true
+	}
+}
+
+// link adds a link of `kind` from the page located at `from` to `to`, both
+// being paths of `s` unless `to` is an absolute URL.
+func (s *Server) link(from, to string, kind LinkKind, location string) {
+	l := Link{From: s.URL + from, To: to, Kind: kind}
+	if strings.HasPrefix(to, "/") {
+		l.To = s.URL + to
+	}
+	if len(location) != 0 {
+		l.Location = s.URL + location
+	}
+
+	s.pages[from].links = append(s.pages[from].links, l)
+	s.links = append(s.links, l)
+}
+
+// serve implements the `http.HandlerFunc` type.
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.hits[r.URL.Path]++
+	s.mu.Unlock()
+
+	if location, ok := s.redirects[r.URL.Path]; ok {
+		http.Redirect(w, r, location, http.StatusMovedPermanently)
+		return
+	}
+
+	p, ok := s.pages[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if p.slow {
+		time.Sleep(s.SlowDelay)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<html><head><title>%s</title></head><body>\n", r.URL.Path)
+	for _, l := range p.links {
+		href := strings.TrimPrefix(l.To, s.URL)
+		if l.Kind == LinkNoFollow {
+			fmt.Fprintf(w, "<a rel=\"nofollow\" href=\"%s\">%s</a>\n", href, href)
+		} else {
+			fmt.Fprintf(w, "<a href=\"%s\">%s</a>\n", href, href)
+		}
+	}
+	fmt.Fprintln(w, "</body></html>")
+}
+
+// Root returns the URL of the root page of `s`.
+func (s *Server) Root() string { return s.URL + "/" }
+
+// SiteMap returns the sorted URLs of the pages of `s` reachable from its root
+// through `crawltest.LinkFollow` links, the root included.
+func (s *Server) SiteMap() []string {
+	urls := make([]string, 0, len(s.tree))
+	for _, path := range s.tree {
+		urls = append(urls, s.URL+path)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// Links returns every `crawltest.Link` of `s`.
+func (s *Server) Links() []Link {
+	return append([]Link(nil), s.links...)
+}
+
+// Targets returns the sorted and distinct URLs linked by the
+// `crawltest.Link`s of `s` of any of `kinds`.
+func (s *Server) Targets(kinds ...LinkKind) []string {
+	seen := make(map[string]bool)
+	for _, l := range s.links {
+		for _, k := range kinds {
+			if l.Kind == k {
+				seen[l.To] = true
+			}
+		}
+	}
+
+	urls := make([]string, 0, len(seen))
+	for u := range seen {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// Hits returns the number of requests received by `s` for `url`.
+//
+// NOTE: This function is thread-safe.
+func (s *Server) Hits(url string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.hits[strings.TrimPrefix(url, s.URL)]
+}
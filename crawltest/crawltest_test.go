@@ -0,0 +1,166 @@
+package crawltest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkKind_String(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mockKind    LinkKind
+		expectedStr string
+	}{
+		{"follow", LinkFollow, "follow"},
+		{"nofollow", LinkNoFollow, "nofollow"},
+		{"broken", LinkBroken, "broken"},
+		{"redirect", LinkRedirect, "redirect"},
+		{"offHost", LinkOffHost, "off-host"},
+		{"unknown", LinkKind(42), "unknown"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedStr, tc.mockKind.String())
+		})
+	}
+}
+
+func TestServer_NewServer(t *testing.T) {
+	testCases := []struct {
+		name             string
+		mockSite         Site
+		expectedPages    int
+		expectedByKind   map[LinkKind]int
+		expectedSiteMap  []string
+		expectedRootLink []string
+	}{
+		{
+			"single",
+			Site{},
+			1,
+			map[LinkKind]int{},
+			[]string{"/"},
+			nil,
+		},
+		{
+			"tree",
+			Site{Pages: 5, FanOut: 2},
+			5,
+			map[LinkKind]int{LinkFollow: 8},
+			[]string{"/", "/page/1", "/page/2", "/page/3", "/page/4"},
+			[]string{"/page/1", "/page/2"},
+		},
+		{
+			"depth",
+			Site{Pages: 100, FanOut: 2, Depth: 2},
+			7,
+			map[LinkKind]int{LinkFollow: 12},
+			[]string{"/", "/page/1", "/page/2", "/page/3", "/page/4", "/page/5", "/page/6"},
+			[]string{"/page/1", "/page/2"},
+		},
+		{
+			"extra",
+			Site{Pages: 2, FanOut: 1, Broken: 2, Redirects: 1, NoFollow: 1, OffHost: 3},
+			2,
+			map[LinkKind]int{LinkFollow: 2, LinkBroken: 2, LinkRedirect: 1, LinkNoFollow: 1, LinkOffHost: 3},
+			[]string{"/", "/page/1"},
+			[]string{"/page/1", "/broken/0", "/redirect/0", "/hidden/0", "http://offhost-0.invalid/", "http://offhost-2.invalid/"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewServer(tc.mockSite)
+			defer s.Close()
+
+			byKind := make(map[LinkKind]int)
+			var rootLinks []string
+			for _, l := range s.Links() {
+				byKind[l.Kind]++
+				if l.From == s.Root() {
+					rootLinks = append(rootLinks, l.To)
+				}
+			}
+
+			var expectedSiteMap, expectedRootLinks []string
+			for _, path := range tc.expectedSiteMap {
+				expectedSiteMap = append(expectedSiteMap, s.URL+path)
+			}
+			for _, link := range tc.expectedRootLink {
+				if link[0] == '/' {
+					link = s.URL + link
+				}
+				expectedRootLinks = append(expectedRootLinks, link)
+			}
+
+			assert.Len(t, s.SiteMap(), tc.expectedPages)
+			assert.Equal(t, tc.expectedByKind, byKind)
+			assert.ElementsMatch(t, expectedSiteMap, s.SiteMap())
+			assert.ElementsMatch(t, expectedRootLinks, rootLinks)
+		})
+	}
+}
+
+func TestServer_serve(t *testing.T) {
+	s := NewServer(Site{Pages: 3, FanOut: 2, Broken: 1, Redirects: 2, NoFollow: 1, Slow: 1, SlowDelay: 50 * time.Millisecond})
+	defer s.Close()
+
+	testCases := []struct {
+		name             string
+		mockPath         string
+		expectedCode     int
+		expectedURL      string
+		expectedContent  []string
+		expectedMinDelay time.Duration
+	}{
+		{
+			"root",
+			"/",
+			http.StatusOK,
+			"/",
+			[]string{`<a href="/page/1">`, `<a href="/broken/0">`, `<a href="/redirect/0">`, `<a rel="nofollow" href="/hidden/0">`},
+			50 * time.Millisecond,
+		},
+		{"page", "/page/2", http.StatusOK, "/page/2", []string{`<a href="/">`}, 0},
+		{"hidden", "/hidden/0", http.StatusOK, "/hidden/0", nil, 0},
+		{"broken", "/broken/0", http.StatusNotFound, "/broken/0", nil, 0},
+		{"redirect", "/redirect/1", http.StatusOK, "/page/1", []string{`<a href="/">`}, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Now()
+			resp, err := http.Get(s.URL + tc.mockPath)
+			assert.Nil(t, err)
+			defer resp.Body.Close()
+
+			content, err := ioutil.ReadAll(resp.Body)
+			assert.Nil(t, err)
+			assert.True(t, time.Since(start) >= tc.expectedMinDelay)
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			assert.Equal(t, s.URL+tc.expectedURL, resp.Request.URL.String())
+			for _, c := range tc.expectedContent {
+				assert.Contains(t, string(content), c)
+			}
+			assert.Equal(t, 1, s.Hits(s.URL+tc.mockPath))
+		})
+	}
+}
+
+func TestServer_Targets(t *testing.T) {
+	s := NewServer(Site{Pages: 3, FanOut: 2, Broken: 4, OffHost: 1})
+	defer s.Close()
+
+	assert.Equal(t, []string{s.URL + "/", s.URL + "/page/1", s.URL + "/page/2"}, s.Targets(LinkFollow))
+	assert.Equal(
+		t,
+		[]string{s.URL + "/broken/0", s.URL + "/broken/1", s.URL + "/broken/2", s.URL + "/broken/3", "http://offhost-0.invalid/"},
+		s.Targets(LinkBroken, LinkOffHost),
+	)
+	assert.Empty(t, s.Targets(LinkRedirect))
+}
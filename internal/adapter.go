@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"context"
+	"sync"
+
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+// Names used by the adapters to report errors and notify events.
+const (
+	filterStage = "filter"
+	mapStage    = "map"
+	expandStage = "expand"
+	tapStage    = "tap"
+)
+
+// processFunc is a named type representing the business logic of an
+// `*internal.adapter`. It returns the `*domain.Target`s to send further in the
+// pipeline or, when there is none, the reason why `t` is discarded.
+type processFunc func(r *Reporter, t *domain.Target) ([]*domain.Target, string)
+
+// adapter is a `struct` implementing the `internal.Pipe` interface on top of a
+// `internal.processFunc`. It handles the `*sync.WaitGroup` accounting so that
+// `process` does not have to.
+type adapter struct {
+	Reporter
+
+	stage   string
+	process processFunc
+}
+
+// Pipe connects `in` and `out` together. Any `*domain.Target` received from
+// `in` is given to `a.process` and the `*domain.Target`s it returns are sent
+// to `out`.
+//
+// NOTE: This function will loop over a channel until `in` is closed. After that
+// it will close `out`.
+func (a *adapter) Pipe(ctx context.Context, wg *sync.WaitGroup, in <-chan *domain.Target, out chan<- *domain.Target) {
+	defer close(out)
+
+	for t := range in {
+		a.NotifyEnter(a.stage, t)
+		if ctx.Err() != nil {
+			a.NotifyDiscard(a.stage, t, ReasonCancelled)
+			wg.Done()
+			continue
+		}
+
+		var targets []*domain.Target
+		found := false
+		results, reason := a.process(&a.Reporter, t)
+		for _, r := range results {
+			if r == nil {
+				continue
+			}
+			found = found || r == t
+			targets = append(targets, r)
+		}
+
+		switch {
+		case len(targets) == 0:
+			a.NotifyDiscard(a.stage, t, reason)
+		case !found:
+			a.NotifyLeave(a.stage, t)
+		}
+
+		wg.Add(len(targets))
+		wg.Done()
+		for _, r := range targets {
+			if r == t {
+				a.NotifyLeave(a.stage, r)
+			} else {
+				a.NotifyEmit(a.stage, r)
+			}
+			out <- r
+		}
+	}
+}
+
+// Filter returns a `internal.Pipe` sending to `out` every `*domain.Target`
+// for which `keep` returns `true` and discarding the others.
+func Filter(keep func(*domain.Target) bool) Pipe {
+	return &adapter{
+		stage: filterStage,
+		process: func(_ *Reporter, t *domain.Target) ([]*domain.Target, string) {
+			if !keep(t) {
+				return nil, ReasonFiltered
+			}
+			return []*domain.Target{t}, ""
+		},
+	}
+}
+
+// Map returns a `internal.Pipe` sending to `out` the `*domain.Target` returned
+// by `fn` in place of the one received. The `*domain.Target` is discarded when
+// `fn` returns `nil` and reported as a `*internal.CrawlError` when `fn` returns
+// an `error`.
+func Map(fn func(*domain.Target) (*domain.Target, error)) Pipe {
+	return &adapter{
+		stage: mapStage,
+		process: func(r *Reporter, t *domain.Target) ([]*domain.Target, string) {
+			mapped, err := fn(t)
+			if err != nil {
+				r.ReportError(mapStage, t.BaseURL, err)
+				return nil, ReasonFailed
+			}
+			return []*domain.Target{mapped}, ReasonFiltered
+		},
+	}
+}
+
+// Expand returns a `internal.Pipe` sending to `out` every `*domain.Target`
+// returned by `fn` in place of the one received, which is discarded when `fn`
+// returns none.
+//
+// NOTE: `domain.NewChildTarget` should be used by `fn` to create new
+// `*domain.Target`s so that their depth is tracked.
+func Expand(fn func(*domain.Target) []*domain.Target) Pipe {
+	return &adapter{
+		stage: expandStage,
+		process: func(_ *Reporter, t *domain.Target) ([]*domain.Target, string) {
+			return fn(t), ReasonFiltered
+		},
+	}
+}
+
+// Tap returns a `internal.Pipe` calling `fn` with every `*domain.Target` before
+// sending it to `out` unchanged.
+func Tap(fn func(*domain.Target)) Pipe {
+	return &adapter{
+		stage: tapStage,
+		process: func(_ *Reporter, t *domain.Target) ([]*domain.Target, string) {
+			fn(t)
+			return []*domain.Target{t}, ""
+		},
+	}
+}
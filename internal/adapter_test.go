@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+// runPipe is an helper function only used for test purposes. It sends
+// `targets` through `p` and returns the `*domain.Target`s sent to `out`, or
+// fails `t` if the `*sync.WaitGroup` accounting of `p` is wrong.
+func runPipe(t *testing.T, p Pipe, targets ...*domain.Target) []*domain.Target {
+	var res []*domain.Target
+	inChan := make(chan *domain.Target)
+	outChan := make(chan *domain.Target)
+	wg := sync.WaitGroup{}
+	wg.Add(len(targets))
+
+	go p.Pipe(context.Background(), &wg, inChan, outChan)
+	go func() {
+		for _, tgt := range targets {
+			inChan <- tgt
+		}
+		close(inChan)
+	}()
+
+	for tgt := range outChan {
+		res = append(res, tgt)
+		wg.Done()
+	}
+
+	done := make(chan struct{})
+	go func() { wg.Wait(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(1 * time.Second):
+		t.Errorf("runPipe: WaitGroup not released")
+	}
+	return res
+}
+
+func TestFilter(t *testing.T) {
+	a, b := domain.NewTarget("http://a.com"), domain.NewTarget("http://b.com")
+
+	res := runPipe(t, Filter(func(t *domain.Target) bool { return t.BaseURL == "http://a.com" }), a, b)
+	assert.Equal(t, []*domain.Target{a}, res)
+}
+
+func TestMap(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mockFn      func(*domain.Target) (*domain.Target, error)
+		expectedURL []string
+		expectedErr int
+	}{
+		{
+			"sameTarget",
+			func(t *domain.Target) (*domain.Target, error) { return t, nil },
+			[]string{"http://a.com"},
+			0,
+		},
+		{
+			"newTarget",
+			func(t *domain.Target) (*domain.Target, error) { return domain.NewTarget(t.BaseURL + "/b"), nil },
+			[]string{"http://a.com/b"},
+			0,
+		},
+		{
+			"nilTarget",
+			func(t *domain.Target) (*domain.Target, error) { return nil, nil },
+			nil,
+			0,
+		},
+		{
+			"error",
+			func(t *domain.Target) (*domain.Target, error) { return nil, errors.New("bad target") },
+			nil,
+			1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var urls []string
+			ms := &mockSink{}
+			p := Map(tc.mockFn)
+			p.(ErrorReporter).SetErrorSink(ms)
+
+			for _, tgt := range runPipe(t, p, domain.NewTarget("http://a.com")) {
+				urls = append(urls, tgt.BaseURL)
+			}
+			assert.Equal(t, tc.expectedURL, urls)
+			assert.Len(t, ms.errs, tc.expectedErr)
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mockFn      func(*domain.Target) []*domain.Target
+		expectedURL []string
+	}{
+		{
+			"none",
+			func(t *domain.Target) []*domain.Target { return nil },
+			nil,
+		},
+		{
+			"withNil",
+			func(t *domain.Target) []*domain.Target { return []*domain.Target{nil} },
+			nil,
+		},
+		{
+			"children",
+			func(t *domain.Target) []*domain.Target {
+				return []*domain.Target{
+					domain.NewChildTarget("http://a.com/b", t),
+					domain.NewChildTarget("http://a.com/c", t),
+				}
+			},
+			[]string{"http://a.com/b", "http://a.com/c"},
+		},
+		{
+			"selfAndChild",
+			func(t *domain.Target) []*domain.Target {
+				return []*domain.Target{t, domain.NewChildTarget("http://a.com/b", t)}
+			},
+			[]string{"http://a.com", "http://a.com/b"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var urls []string
+			for _, tgt := range runPipe(t, Expand(tc.mockFn), domain.NewTarget("http://a.com")) {
+				urls = append(urls, tgt.BaseURL)
+			}
+			assert.Equal(t, tc.expectedURL, urls)
+		})
+	}
+}
+
+func TestTap(t *testing.T) {
+	var seen []string
+	a, b := domain.NewTarget("http://a.com"), domain.NewTarget("http://b.com")
+
+	res := runPipe(t, Tap(func(t *domain.Target) { seen = append(seen, t.BaseURL) }), a, b)
+	assert.Equal(t, []*domain.Target{a, b}, res)
+	assert.Equal(t, []string{"http://a.com", "http://b.com"}, seen)
+}
+
+func TestAdapter_Pipe_events(t *testing.T) {
+	mo := &mockObserver{}
+	p := Expand(func(t *domain.Target) []*domain.Target {
+		return []*domain.Target{domain.NewChildTarget("http://a.com/b", t)}
+	})
+	p.(Observable).SetObservers(mo)
+
+	runPipe(t, p, domain.NewTarget("http://a.com"))
+
+	var kinds []EventKind
+	for _, e := range mo.events {
+		kinds = append(kinds, e.Kind)
+	}
+	assert.Equal(t, []EventKind{EventEnter, EventLeave, EventEmit}, kinds)
+}
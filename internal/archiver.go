@@ -1,13 +1,19 @@
 package internal
 
 import (
+	"context"
 	"sync"
 
 	"github.com/timtosi/mcrawler/internal/domain"
 )
 
+// archiverStage is the name used by `*internal.Archiver` to notify events.
+const archiverStage = "archiver"
+
 // Archiver is a `struct` checking that a given URL has not already been seen.
 type Archiver struct {
+	Reporter
+
 	archive map[string]bool
 	mu      *sync.Mutex
 }
@@ -36,18 +42,61 @@ func (a *Archiver) IsAlreadySeen(url string) bool {
 	return false
 }
 
+// CheckRedirect implements the `internal.RedirectChecker` interface. It
+// returns `internal.ReasonAlreadySeen` if `link` is already seen, or stores
+// it in `a.archive` otherwise.
+func (a *Archiver) CheckRedirect(link string) string {
+	if a.IsAlreadySeen(link) {
+		return ReasonAlreadySeen
+	}
+	return ""
+}
+
+// Seen returns every URL stored in `a.archive`.
+//
+// NOTE: This function is thread-safe.
+func (a *Archiver) Seen() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	seen := make([]string, 0, len(a.archive))
+	for url := range a.archive {
+		seen = append(seen, url)
+	}
+	return seen
+}
+
+// Restore stores every URL of `urls` in `a.archive` so that they are
+// considered already seen, e.g. when resuming a crawl.
+//
+// NOTE: This function is thread-safe.
+func (a *Archiver) Restore(urls []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, url := range urls {
+		a.archive[url] = true
+	}
+}
+
 // Pipe connects `in` and `out` together. Any `t` received from `in` will
 // be checked against `a.archive` and sent to `out` if not already seen.
 //
 // NOTE: This function will loop over a channel until `in` is closed. After that
 // it will close `out`.
-func (a *Archiver) Pipe(wg *sync.WaitGroup, in <-chan *domain.Target, out chan<- *domain.Target) {
+func (a *Archiver) Pipe(ctx context.Context, wg *sync.WaitGroup, in <-chan *domain.Target, out chan<- *domain.Target) {
 	defer close(out)
 
 	for t := range in {
-		if a.IsAlreadySeen(t.BaseURL) {
+		a.NotifyEnter(archiverStage, t)
+		if ctx.Err() != nil {
+			a.NotifyDiscard(archiverStage, t, ReasonCancelled)
+			wg.Done()
+		} else if a.IsAlreadySeen(t.BaseURL) {
+			a.NotifyDiscard(archiverStage, t, ReasonAlreadySeen)
 			wg.Done()
 		} else {
+			a.NotifyLeave(archiverStage, t)
 			out <- t
 		}
 	}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"sync"
 	"testing"
 	"time"
@@ -71,6 +72,58 @@ func TestArchiver_IsAlreadySeen(t *testing.T) {
 	}
 }
 
+func TestArchiver_Seen(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mockArchived []string
+	}{
+		{"empty", []string{}},
+		{"regular", []string{"https://fakeSeen.com", "https://notaSeen.com"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := NewArchiver()
+			for _, k := range tc.mockArchived {
+				a.archive[k] = true
+			}
+			assert.ElementsMatch(t, tc.mockArchived, a.Seen())
+		})
+	}
+}
+
+func TestArchiver_Restore(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mockArchived []string
+		mockRestored []string
+		expected     []string
+	}{
+		{"empty", []string{}, []string{}, []string{}},
+		{
+			"regular",
+			[]string{"https://fakeSeen.com"},
+			[]string{"https://fakeSeen.com", "https://restored.com"},
+			[]string{"https://fakeSeen.com", "https://restored.com"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := NewArchiver()
+			for _, k := range tc.mockArchived {
+				a.archive[k] = true
+			}
+
+			a.Restore(tc.mockRestored)
+			assert.ElementsMatch(t, tc.expected, a.Seen())
+			for _, k := range tc.mockRestored {
+				assert.True(t, a.IsAlreadySeen(k))
+			}
+		})
+	}
+}
+
 func TestArchiver_Pipe(t *testing.T) {
 	testCases := []struct {
 		name             string
@@ -119,7 +172,7 @@ func TestArchiver_Pipe(t *testing.T) {
 			wg := sync.WaitGroup{}
 			wg.Add(tc.mockSeen)
 
-			go a.Pipe(&wg, inChan, outChan)
+			go a.Pipe(context.Background(), &wg, inChan, outChan)
 			inChan <- tc.mockTarget
 
 			select {
@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+// assetStage is the name used by the `internal.Pipe` returned by
+// `internal.SkipAssets` to notify events.
+const assetStage = "assets"
+
+// assetExtensions are the extensions of the assets, i.e. of the resources that
+// are never HTML, e.g. images, documents, fonts, media or archives.
+//
+// NOTE: This list is explicit rather than based on the `mime` package, whose
+// media types depend on the files of the host, e.g. `/etc/mime.types`.
+// Resources with any other extension, e.g. `.php` or `.xml`, are classified by
+// their `Content-Type` once fetched.
+var assetExtensions = map[string]bool{
+	".7z": true, ".apk": true, ".avi": true, ".avif": true, ".bin": true,
+	".bmp": true, ".bz2": true, ".css": true, ".csv": true, ".deb": true,
+	".dmg": true, ".doc": true, ".docx": true, ".eot": true, ".epub": true,
+	".exe": true, ".flac": true, ".gif": true, ".gz": true, ".ico": true,
+	".iso": true, ".jar": true, ".jpeg": true, ".jpg": true, ".js": true,
+	".m4a": true, ".mkv": true, ".mov": true, ".mp3": true, ".mp4": true,
+	".mpeg": true, ".msi": true, ".odp": true, ".ods": true, ".odt": true,
+	".ogg": true, ".otf": true, ".pdf": true, ".png": true, ".ppt": true,
+	".pptx": true, ".rar": true, ".rpm": true, ".svg": true, ".tar": true,
+	".tgz": true, ".tif": true, ".tiff": true, ".ttf": true, ".wav": true,
+	".webm": true, ".webp": true, ".woff": true, ".woff2": true, ".xls": true,
+	".xlsx": true, ".xz": true, ".zip": true,
+}
+
+// isAsset returns `true` if the extension of the path of `link` is one of
+// `internal.assetExtensions`, e.g. `.png` or `.zip`.
+func isAsset(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	return assetExtensions[strings.ToLower(path.Ext(u.Path))]
+}
+
+// isAssetType returns `true` if the `Content-Type` of `header` is a valid media
+// type that is not HTML.
+func isAssetType(header http.Header) bool {
+	value := header.Get("Content-Type")
+	if _, _, err := mime.ParseMediaType(value); err != nil {
+		return false
+	}
+	return !acceptContentType(HTMLContentTypes, header)
+}
+
+// SkipAssets returns a `internal.Pipe` discarding every `*domain.Target`
+// marked as `domain.Target.Asset`, so that the ones placed after it, e.g. the
+// `*extractor.Extractor`, never receive them. It should be placed after the
+// `internal.Pipe`s recording the pages crawled, e.g. the `*mapper.Mapper`.
+func SkipAssets() Pipe {
+	return &adapter{
+		stage: assetStage,
+		process: func(_ *Reporter, t *domain.Target) ([]*domain.Target, string) {
+			if t.Asset {
+				return nil, ReasonAsset
+			}
+			return []*domain.Target{t}, ""
+		},
+	}
+}
@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+func TestIsAsset(t *testing.T) {
+	for link, expected := range map[string]bool{
+		"http://a.com":             false,
+		"http://a.com/":            false,
+		"http://a.com/page":        false,
+		"http://a.com/index.html":  false,
+		"http://a.com/logo.png":    true,
+		"http://a.com/doc.pdf?v=1": true,
+		"http://a.com/release.ISO": true,
+		"http://a.com/src.tar.gz":  true,
+		"http://a.com/%zz.png":     false,
+		"http://a.com/index.php":   false,
+		"http://a.com/default.asp": false,
+		"http://a.com/feed.xml":    false,
+		"http://a.com/unknown.foo": false,
+	} {
+		assert.Equal(t, expected, isAsset(link), link)
+	}
+}
+
+func TestIsAssetType(t *testing.T) {
+	for contentType, expected := range map[string]bool{
+		"":                         false,
+		"text/html; charset=utf-8": false,
+		"application/xhtml+xml":    false,
+		"image/png":                true,
+		"application/zip":          true,
+		"text/html; =":             false,
+	} {
+		assert.Equal(t, expected, isAssetType(http.Header{"Content-Type": {contentType}}), contentType)
+	}
+}
+
+func TestSkipAssets(t *testing.T) {
+	page := domain.NewTarget("http://a.com")
+	asset := domain.NewTarget("http://a.com/logo.png")
+	asset.Asset = true
+
+	assert.Equal(t, []*domain.Target{page}, runPipe(t, SkipAssets(), page, asset))
+}
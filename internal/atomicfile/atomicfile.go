@@ -0,0 +1,60 @@
+// Package atomicfile saves the state of a crawl on disk so that an
+// interrupted save never leaves a partially written file behind.
+package atomicfile
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Save atomically writes the file `name` in the `dir` directory with `write`,
+// replacing any previous file found there. It returns an `error` if something
+// bad occurs.
+//
+// NOTE: `write` writes a temporary file of `dir` which is flushed to disk and
+// renamed once complete, so that a crash never leaves an empty file behind.
+func Save(dir, name string, write func(io.Writer) error) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("Save: %v", err)
+	}
+
+	f, err := ioutil.TempFile(dir, name)
+	if err != nil {
+		return fmt.Errorf("Save: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	if err := write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("Save: %v", err)
+	} else if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("Save: %v", err)
+	} else if err := f.Close(); err != nil {
+		return fmt.Errorf("Save: %v", err)
+	}
+
+	if err := os.Rename(f.Name(), filepath.Join(dir, name)); err != nil {
+		return fmt.Errorf("Save: %v", err)
+	}
+	return nil
+}
+
+// Load reads the file `name` of the `dir` directory with `read`. It returns an
+// `error` if something bad occurs, matching `os.ErrNotExist` with
+// `errors.Is` when there is no such file.
+func Load(dir, name string, read func(io.Reader) error) error {
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return fmt.Errorf("Load: %w", err)
+	}
+	defer f.Close()
+
+	if err := read(f); err != nil {
+		return fmt.Errorf("Load: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package atomicfile
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "atomicfile")
+	if err != nil {
+		t.Fatalf("TestSaveLoad: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	sub := filepath.Join(dir, "sub")
+
+	read := func(content *string) func(io.Reader) error {
+		return func(r io.Reader) error {
+			b, err := ioutil.ReadAll(r)
+			*content = string(b)
+			return err
+		}
+	}
+
+	var content string
+	err = Load(sub, "state", read(&content))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	assert.Nil(t, Save(sub, "state", func(w io.Writer) error {
+		_, err := io.WriteString(w, "v1")
+		return err
+	}))
+	assert.Nil(t, Load(sub, "state", read(&content)))
+	assert.Equal(t, "v1", content)
+
+	assert.NotNil(t, Save(sub, "state", func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return errors.New("write failed")
+	}))
+	assert.Nil(t, Load(sub, "state", read(&content)))
+	assert.Equal(t, "v1", content)
+
+	files, err := ioutil.ReadDir(sub)
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+
+	assert.NotNil(t, Load(sub, "state", func(io.Reader) error { return errors.New("bad state") }))
+}
@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrMaxPages is returned when the maximum number of pages allowed by a
+	// `*internal.Budget` has been fetched.
+	ErrMaxPages = errors.New("max pages budget exhausted")
+	// ErrMaxBytes is returned when the maximum number of bytes allowed by a
+	// `*internal.Budget` has been downloaded.
+	ErrMaxBytes = errors.New("max bytes budget exhausted")
+	// ErrMaxDuration is returned when the maximum crawl duration allowed by a
+	// `*internal.Budget` has elapsed.
+	ErrMaxDuration = errors.New("max duration budget exhausted")
+)
+
+// Budget is a `struct` keeping track of the resources spent by a crawl. Once
+// any of its limits is reached, the `Budget` is exhausted for good.
+//
+// NOTE: A limit set to zero means no limit.
+type Budget struct {
+	maxPages    int
+	maxBytes    int64
+	maxDuration time.Duration
+
+	pages int
+	bytes int64
+	err   error
+	done  chan struct{}
+	timer *time.Timer
+	mu    *sync.Mutex
+}
+
+// NewBudget returns a new `*internal.Budget` that can be configured through
+// `opts` functions.
+func NewBudget(opts ...func(*Budget)) *Budget {
+	b := &Budget{
+		done: make(chan struct{}),
+		mu:   &sync.Mutex{},
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// WithMaxPages limits to `n` the number of pages a `*internal.Budget` allows
+// to fetch.
+func WithMaxPages(n int) func(*Budget) {
+	return func(b *Budget) { b.maxPages = n }
+}
+
+// WithMaxBytes limits to `n` the number of bytes a `*internal.Budget` allows
+// to download.
+func WithMaxBytes(n int64) func(*Budget) {
+	return func(b *Budget) { b.maxBytes = n }
+}
+
+// WithMaxDuration limits to `d` the time a `*internal.Budget` allows to
+// crawl, starting from the `b.Start` call.
+func WithMaxDuration(d time.Duration) func(*Budget) {
+	return func(b *Budget) { b.maxDuration = d }
+}
+
+// exhaust marks `b` as exhausted because of `err` and closes `b.done`. Only
+// the first call has an effect.
+//
+// NOTE: `b.mu` must be held by the caller.
+func (b *Budget) exhaust(err error) {
+	if b.err == nil {
+		b.err = err
+		close(b.done)
+	}
+}
+
+// Start starts the clock of the duration limit of `b`, if any.
+//
+// NOTE: This function is thread-safe.
+func (b *Budget) Start() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.maxDuration > 0 && b.timer == nil {
+		b.timer = time.AfterFunc(b.maxDuration, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.exhaust(ErrMaxDuration)
+		})
+	}
+}
+
+// Stop stops the clock of the duration limit of `b`, if any.
+//
+// NOTE: This function is thread-safe.
+func (b *Budget) Stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+}
+
+// ReservePage accounts for a new page about to be fetched. It returns the
+// `error` that exhausted `b` if the page does not fit in it.
+//
+// NOTE: This function is thread-safe.
+func (b *Budget) ReservePage() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err != nil {
+		return b.err
+	} else if b.maxPages > 0 && b.pages >= b.maxPages {
+		b.exhaust(ErrMaxPages)
+		return b.err
+	}
+	b.pages++
+	return nil
+}
+
+// AddBytes accounts for `n` more bytes downloaded. It returns the `error` that
+// exhausted `b` if they do not fit in it.
+//
+// NOTE: This function is thread-safe.
+func (b *Budget) AddBytes(n int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err != nil {
+		return b.err
+	}
+	b.bytes += int64(n)
+	if b.maxBytes > 0 && b.bytes > b.maxBytes {
+		b.exhaust(ErrMaxBytes)
+	}
+	return b.err
+}
+
+// Done returns a channel closed as soon as `b` is exhausted.
+func (b *Budget) Done() <-chan struct{} {
+	return b.done
+}
+
+// Err returns the `error` that exhausted `b` or `nil` if it is not exhausted.
+//
+// NOTE: This function is thread-safe.
+func (b *Budget) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.err
+}
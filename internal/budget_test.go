@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudget_NewBudget(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mockOpts []func(*Budget)
+	}{
+		{"noLimit", nil},
+		{"allLimits", []func(*Budget){WithMaxPages(1), WithMaxBytes(1), WithMaxDuration(time.Second)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := NewBudget(tc.mockOpts...)
+			assert.NotNil(t, b)
+			assert.Nil(t, b.Err())
+		})
+	}
+}
+
+func TestBudget_ReservePage(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mockMaxPages int
+		mockReserved int
+		expectedErr  error
+	}{
+		{"noLimit", 0, 1000, nil},
+		{"underLimit", 3, 2, nil},
+		{"lastPage", 3, 3, nil},
+		{"overLimit", 3, 4, ErrMaxPages},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var err error
+			b := NewBudget(WithMaxPages(tc.mockMaxPages))
+
+			for i := 0; i < tc.mockReserved; i++ {
+				err = b.ReservePage()
+			}
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expectedErr, b.Err())
+		})
+	}
+}
+
+func TestBudget_AddBytes(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mockMaxBytes int64
+		mockBytes    []int
+		expectedErr  error
+	}{
+		{"noLimit", 0, []int{1 << 20, 1 << 20}, nil},
+		{"underLimit", 10, []int{4, 5}, nil},
+		{"atLimit", 10, []int{5, 5}, nil},
+		{"overLimit", 10, []int{5, 6}, ErrMaxBytes},
+		{"alreadyExhausted", 10, []int{11, 0}, ErrMaxBytes},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var err error
+			b := NewBudget(WithMaxBytes(tc.mockMaxBytes))
+
+			for _, n := range tc.mockBytes {
+				err = b.AddBytes(n)
+			}
+			assert.Equal(t, tc.expectedErr, err)
+		})
+	}
+}
+
+func TestBudget_Start(t *testing.T) {
+	testCases := []struct {
+		name            string
+		mockMaxDuration time.Duration
+		expectedErr     error
+	}{
+		{"noLimit", 0, nil},
+		{"elapsed", 10 * time.Millisecond, ErrMaxDuration},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := NewBudget(WithMaxDuration(tc.mockMaxDuration))
+			b.Start()
+			defer b.Stop()
+
+			select {
+			case <-b.Done():
+			case <-time.After(100 * time.Millisecond):
+			}
+			assert.Equal(t, tc.expectedErr, b.Err())
+			assert.Equal(t, tc.expectedErr, b.ReservePage())
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/timtosi/mcrawler/internal/atomicfile"
+)
+
+// fileName is the name of the file holding a `*cache.Cache` in its directory.
+const fileName = "cache.json"
+
+// Entry is a `struct` representing a web page fetched during a previous
+// crawl. `ETag` and `LastModified` are the validators sent by the server, if
+// any, `Modified` is the last time the content of the page is known to have
+// changed and `Digest` is the SHA-256 digest of this content.
+//
+// `Content` is the content itself, only kept when there is a validator so that
+// it can be reused when the page is answered with `304 Not Modified`.
+type Entry struct {
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Modified     time.Time `json:"modified"`
+	Digest       string    `json:"digest"`
+	Content      []byte    `json:"content,omitempty"`
+}
+
+// Cache is a `struct` keeping the web pages fetched so that they can be
+// fetched again with conditional requests in a later crawl and their changes
+// detected. It also keeps track of the web pages found changed or unchanged
+// during the current crawl.
+type Cache struct {
+	entries map[string]*Entry
+	changed map[string]bool
+	mu      *sync.RWMutex
+}
+
+// NewCache returns a new empty `*cache.Cache`.
+func NewCache() *Cache {
+	return &Cache{
+		entries: make(map[string]*Entry),
+		changed: make(map[string]bool),
+		mu:      &sync.RWMutex{},
+	}
+}
+
+// Get returns the `*cache.Entry` of the web page located at `url`, or `false`
+// if there is none.
+//
+// NOTE: This function is thread-safe.
+func (c *Cache) Get(url string) (*Entry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	e, ok := c.entries[url]
+	return e, ok
+}
+
+// digest returns the hexadecimal SHA-256 digest of `content`.
+func digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Put stores `content` as the content of the web page located at `url` along
+// with the validators found in `header`, if any. The page is marked as changed
+// unless `content` is the same as in its previous `*cache.Entry`, and `true`
+// is returned if it is.
+//
+// NOTE: Only the digest of `content` is stored when `header` has no
+// validator, since the page cannot be answered with `304 Not Modified`.
+//
+// NOTE: This function is thread-safe.
+func (c *Cache) Put(url string, header http.Header, content []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d := digest(content)
+	prev, ok := c.entries[url]
+	unchanged := ok && prev.Digest == d
+	c.changed[url] = !unchanged
+
+	e := &Entry{
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		Digest:       d,
+	}
+	if len(e.ETag) != 0 || len(e.LastModified) != 0 {
+		e.Content = content
+	}
+
+	if unchanged {
+		e.Modified = prev.Modified
+	} else if lm, err := http.ParseTime(e.LastModified); err == nil {
+		e.Modified = lm.UTC()
+	} else {
+		e.Modified = time.Now().UTC()
+	}
+	c.entries[url] = e
+	return !unchanged
+}
+
+// Delete removes the web page located at `url` from `c`, e.g. when it does not
+// exist anymore.
+//
+// NOTE: This function is thread-safe.
+func (c *Cache) Delete(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, url)
+	delete(c.changed, url)
+}
+
+// Alias makes the web page located at `url` share the `*cache.Entry` of the
+// one located at `target`, e.g. when `url` redirects to `target`, so that it
+// can be looked up with either of them. It does nothing if `url` and `target`
+// are the same or if there is no such `*cache.Entry`.
+//
+// NOTE: Only `target` is marked as changed or unchanged.
+//
+// NOTE: This function is thread-safe.
+func (c *Cache) Alias(url, target string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[target]; ok && url != target {
+		c.entries[url] = e
+	}
+}
+
+// Keep marks the web page located at `url` as unchanged, e.g. when it has
+// been answered with `304 Not Modified`.
+//
+// NOTE: This function is thread-safe.
+func (c *Cache) Keep(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.changed[url] = false
+}
+
+// LastModified returns the last time the content of the web page located at
+// `url` is known to have changed, or `false` if it is unknown.
+//
+// NOTE: This function is thread-safe.
+func (c *Cache) LastModified(url string) (time.Time, bool) {
+	e, ok := c.Get(url)
+	if !ok {
+		return time.Time{}, false
+	}
+	return e.Modified, true
+}
+
+// Changed returns the sorted URLs of the web pages found changed during the
+// current crawl when `changed` is `true`, or unchanged otherwise.
+//
+// NOTE: This function is thread-safe.
+func (c *Cache) Changed(changed bool) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var urls []string
+	for url, ch := range c.changed {
+		if ch == changed {
+			urls = append(urls, url)
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// Write writes the entries of `c` to `w` as JSON or returns an `error` if
+// something bad occurs.
+//
+// NOTE: This function is thread-safe.
+func (c *Cache) Write(w io.Writer) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if err := json.NewEncoder(w).Encode(c.entries); err != nil {
+		return fmt.Errorf("Write: %v", err)
+	}
+	return nil
+}
+
+// Read reads a `*cache.Cache` written by `Cache.Write` from `r` or returns an
+// `error` if something bad occurs.
+func Read(r io.Reader) (*Cache, error) {
+	c := NewCache()
+	if err := json.NewDecoder(r).Decode(&c.entries); err != nil {
+		return nil, fmt.Errorf("Read: %v", err)
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]*Entry)
+	}
+	return c, nil
+}
+
+// Save atomically writes `c` in the `dir` directory, replacing any previous
+// cache found there. It returns an `error` if something bad occurs.
+func Save(dir string, c *Cache) error {
+	return atomicfile.Save(dir, fileName, c.Write)
+}
+
+// Load reads the cache saved in the `dir` directory and returns it, or an
+// empty `*cache.Cache` if there is none. It returns an `error` if something
+// bad occurs.
+func Load(dir string) (*Cache, error) {
+	var c *Cache
+	err := atomicfile.Load(dir, fileName, func(r io.Reader) (err error) {
+		c, err = Read(r)
+		return err
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return NewCache(), nil
+	} else if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache_Put(t *testing.T) {
+	lastModified := "Thu, 14 Mar 2019 15:09:26 GMT"
+
+	testCases := []struct {
+		name             string
+		mockPrevContent  []byte
+		mockHeader       http.Header
+		mockContent      []byte
+		expectedChanged  bool
+		expectedModified func(prev time.Time) time.Time
+	}{
+		{
+			"new",
+			nil,
+			http.Header{"Etag": {`"v1"`}},
+			[]byte("content"),
+			true,
+			nil,
+		},
+		{
+			"lastModified",
+			nil,
+			http.Header{"Last-Modified": {lastModified}},
+			[]byte("content"),
+			true,
+			func(time.Time) time.Time { return time.Date(2019, 3, 14, 15, 9, 26, 0, time.UTC) },
+		},
+		{
+			"unchanged",
+			[]byte("content"),
+			http.Header{"Etag": {`"v2"`}},
+			[]byte("content"),
+			false,
+			func(prev time.Time) time.Time { return prev },
+		},
+		{
+			"changed",
+			[]byte("old content"),
+			http.Header{"Etag": {`"v2"`}},
+			[]byte("content"),
+			true,
+			nil,
+		},
+		{
+			"noValidator",
+			[]byte("old content"),
+			http.Header{},
+			[]byte("content"),
+			true,
+			nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewCache()
+			prevModified := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+			if tc.mockPrevContent != nil {
+				c.entries["http://a.com"] = &Entry{ETag: `"v1"`, Modified: prevModified, Digest: digest(tc.mockPrevContent)}
+			}
+
+			assert.Equal(t, tc.expectedChanged, c.Put("http://a.com", tc.mockHeader, tc.mockContent))
+			assert.Equal(t, tc.expectedChanged, len(c.Changed(true)) == 1)
+			assert.Equal(t, !tc.expectedChanged, len(c.Changed(false)) == 1)
+
+			e, ok := c.Get("http://a.com")
+			assert.True(t, ok)
+			assert.Equal(t, digest(tc.mockContent), e.Digest)
+			if len(tc.mockHeader) != 0 {
+				assert.Equal(t, tc.mockContent, e.Content)
+			} else {
+				assert.Nil(t, e.Content)
+			}
+			assert.Equal(t, tc.mockHeader.Get("ETag"), e.ETag)
+			if tc.expectedModified != nil {
+				assert.Equal(t, tc.expectedModified(prevModified), e.Modified)
+			} else {
+				assert.True(t, e.Modified.After(prevModified))
+			}
+		})
+	}
+}
+
+func TestCache_Keep(t *testing.T) {
+	c := NewCache()
+	c.Put("http://a.com", http.Header{"Etag": {`"v1"`}}, []byte("a"))
+	c.Put("http://b.com", http.Header{"Etag": {`"v1"`}}, []byte("b"))
+	c.Keep("http://b.com")
+
+	assert.Equal(t, []string{"http://a.com"}, c.Changed(true))
+	assert.Equal(t, []string{"http://b.com"}, c.Changed(false))
+
+	_, ok := c.LastModified("http://a.com")
+	assert.True(t, ok)
+	_, ok = c.LastModified("http://c.com")
+	assert.False(t, ok)
+}
+
+func TestCache_AliasDelete(t *testing.T) {
+	c := NewCache()
+	c.Put("http://a.com/new", http.Header{"Etag": {`"v1"`}}, []byte("a"))
+	c.Alias("http://a.com/old", "http://a.com/new")
+	c.Alias("http://a.com/other", "http://a.com/missing")
+
+	old, ok := c.Get("http://a.com/old")
+	assert.True(t, ok)
+	e, _ := c.Get("http://a.com/new")
+	assert.Equal(t, e, old)
+	_, ok = c.Get("http://a.com/other")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"http://a.com/new"}, c.Changed(true))
+
+	c.Delete("http://a.com/new")
+	_, ok = c.Get("http://a.com/new")
+	assert.False(t, ok)
+	assert.Empty(t, c.Changed(true))
+}
+
+func TestCache_WriteRead(t *testing.T) {
+	c := NewCache()
+	c.Put("http://a.com", http.Header{"Etag": {`"v1"`}}, []byte("a"))
+	c.Put("http://b.com", http.Header{"Last-Modified": {"Thu, 14 Mar 2019 15:09:26 GMT"}}, []byte{0, 1, 2})
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, c.Write(buf))
+
+	res, err := Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, c.entries, res.entries)
+	assert.Empty(t, res.Changed(true))
+
+	_, err = Read(bytes.NewBufferString("not json"))
+	assert.NotNil(t, err)
+}
+
+func TestCache_SaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatalf("TestCache_SaveLoad: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	empty, err := Load(dir)
+	assert.Nil(t, err)
+	assert.Empty(t, empty.entries)
+
+	c := NewCache()
+	c.Put("http://a.com", http.Header{"Etag": {`"v1"`}}, []byte("a"))
+	assert.Nil(t, Save(dir, c))
+
+	res, err := Load(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, c.entries, res.entries)
+}
@@ -0,0 +1,152 @@
+package checkpoint
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/timtosi/mcrawler/internal/atomicfile"
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+const (
+	// fileName is the name of the file holding a `*checkpoint.Checkpoint` in
+	// its directory.
+	fileName = "checkpoint.txt"
+	// header is the first line of a checkpoint file.
+	header = "# mcrawler checkpoint v1"
+
+	frontierSection = "[frontier]"
+	seenSection     = "[seen]"
+	siteMapSection  = "[sitemap]"
+)
+
+// Checkpoint is a `struct` representing the state of a crawl that can be
+// saved on disk and loaded back to resume it.
+type Checkpoint struct {
+	Frontier []*domain.Target
+	Seen     []string
+	SiteMap  []string
+}
+
+// Write writes `cp` to `w` or returns an `error` if something bad occurs.
+//
+// NOTE: The format is a text file split in sections, one element per line.
+// Each field is quoted with `strconv.Quote` and frontier fields are separated
+// by tabs.
+func (cp *Checkpoint) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, header)
+	fmt.Fprintln(bw, frontierSection)
+	for _, t := range cp.Frontier {
+		fmt.Fprintf(bw, "%d\t%s\t%s\n", t.Depth, strconv.Quote(t.Parent), strconv.Quote(t.BaseURL))
+	}
+	fmt.Fprintln(bw, seenSection)
+	for _, u := range cp.Seen {
+		fmt.Fprintln(bw, strconv.Quote(u))
+	}
+	fmt.Fprintln(bw, siteMapSection)
+	for _, u := range cp.SiteMap {
+		fmt.Fprintln(bw, strconv.Quote(u))
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("Write: %v", err)
+	}
+	return nil
+}
+
+// parseTarget parses a frontier `line` and returns the matching
+// `*domain.Target` or an `error` if `line` is malformed.
+func parseTarget(line string) (*domain.Target, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("parseTarget: %d fields found in %q", len(fields), line)
+	}
+
+	depth, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("parseTarget: %v", err)
+	}
+	parent, err := strconv.Unquote(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("parseTarget: %v in %q", err, line)
+	}
+	baseURL, err := strconv.Unquote(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("parseTarget: %v in %q", err, line)
+	}
+	return &domain.Target{BaseURL: baseURL, Depth: depth, Parent: parent}, nil
+}
+
+// Read reads a `*checkpoint.Checkpoint` written by `Checkpoint.Write` from `r`
+// or returns an `error` if something bad occurs.
+func Read(r io.Reader) (*Checkpoint, error) {
+	cp := &Checkpoint{}
+	section := ""
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	if !scanner.Scan() || scanner.Text() != header {
+		return nil, fmt.Errorf("Read: missing %q header", header)
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch line {
+		case frontierSection, seenSection, siteMapSection:
+			section = line
+			continue
+		}
+
+		if section == frontierSection {
+			t, err := parseTarget(line)
+			if err != nil {
+				return nil, fmt.Errorf("Read: %v", err)
+			}
+			cp.Frontier = append(cp.Frontier, t)
+			continue
+		}
+
+		u, err := strconv.Unquote(line)
+		if err != nil {
+			return nil, fmt.Errorf("Read: %v in %q", err, line)
+		}
+		switch section {
+		case seenSection:
+			cp.Seen = append(cp.Seen, u)
+		case siteMapSection:
+			cp.SiteMap = append(cp.SiteMap, u)
+		default:
+			return nil, fmt.Errorf("Read: %q found outside of any section", line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Read: %v", err)
+	}
+	return cp, nil
+}
+
+// Save atomically writes `cp` in the `dir` directory, replacing any previous
+// checkpoint found there. It returns an `error` if something bad occurs.
+func Save(dir string, cp *Checkpoint) error {
+	return atomicfile.Save(dir, fileName, cp.Write)
+}
+
+// Load reads the checkpoint saved in the `dir` directory and returns it or an
+// `error` if something bad occurs.
+func Load(dir string) (*Checkpoint, error) {
+	var cp *Checkpoint
+	err := atomicfile.Load(dir, fileName, func(r io.Reader) (err error) {
+		cp, err = Read(r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
@@ -0,0 +1,173 @@
+package checkpoint
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+func TestCheckpoint_Write(t *testing.T) {
+	testCases := []struct {
+		name           string
+		mockCheckpoint *Checkpoint
+		expected       string
+	}{
+		{
+			"empty",
+			&Checkpoint{},
+			"# mcrawler checkpoint v1\n[frontier]\n[seen]\n[sitemap]\n",
+		},
+		{
+			"regular",
+			&Checkpoint{
+				Frontier: []*domain.Target{
+					{BaseURL: "https://www.checkpoint.com/about", Depth: 1, Parent: "https://www.checkpoint.com"},
+				},
+				Seen:    []string{"https://www.checkpoint.com"},
+				SiteMap: []string{"https://www.checkpoint.com"},
+			},
+			"# mcrawler checkpoint v1\n[frontier]\n" +
+				"1\t\"https://www.checkpoint.com\"\t\"https://www.checkpoint.com/about\"\n" +
+				"[seen]\n\"https://www.checkpoint.com\"\n" +
+				"[sitemap]\n\"https://www.checkpoint.com\"\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var res bytes.Buffer
+			assert.Nil(t, tc.mockCheckpoint.Write(&res))
+			assert.Equal(t, tc.expected, res.String())
+		})
+	}
+}
+
+func TestCheckpoint_Read(t *testing.T) {
+	testCases := []struct {
+		name               string
+		mockContent        string
+		expected           *Checkpoint
+		expectedAssertFunc func(assert.TestingT, interface{}, ...interface{}) bool
+	}{
+		{
+			"empty",
+			"# mcrawler checkpoint v1\n[frontier]\n[seen]\n[sitemap]\n",
+			&Checkpoint{},
+			assert.Nil,
+		},
+		{
+			"regular",
+			"# mcrawler checkpoint v1\n[frontier]\n" +
+				"0\t\"\"\t\"https://www.checkpoint.com\"\n" +
+				"2\t\"https://www.checkpoint.com/a\"\t\"https://www.checkpoint.com/a\\tb\"\n" +
+				"[seen]\n\"https://www.checkpoint.com/seen\"\n" +
+				"[sitemap]\n\"https://www.checkpoint.com/seen\"\n",
+			&Checkpoint{
+				Frontier: []*domain.Target{
+					{BaseURL: "https://www.checkpoint.com"},
+					{BaseURL: "https://www.checkpoint.com/a\tb", Depth: 2, Parent: "https://www.checkpoint.com/a"},
+				},
+				Seen:    []string{"https://www.checkpoint.com/seen"},
+				SiteMap: []string{"https://www.checkpoint.com/seen"},
+			},
+			assert.Nil,
+		},
+		{
+			"noHeader",
+			"[frontier]\n[seen]\n[sitemap]\n",
+			nil,
+			assert.NotNil,
+		},
+		{
+			"badDepth",
+			"# mcrawler checkpoint v1\n[frontier]\nzero\t\"\"\t\"https://www.checkpoint.com\"\n",
+			nil,
+			assert.NotNil,
+		},
+		{
+			"missingField",
+			"# mcrawler checkpoint v1\n[frontier]\n0\t\"https://www.checkpoint.com\"\n",
+			nil,
+			assert.NotNil,
+		},
+		{
+			"unquotedURL",
+			"# mcrawler checkpoint v1\n[seen]\nhttps://www.checkpoint.com\n",
+			nil,
+			assert.NotNil,
+		},
+		{
+			"noSection",
+			"# mcrawler checkpoint v1\n\"https://www.checkpoint.com\"\n",
+			nil,
+			assert.NotNil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := Read(bytes.NewBufferString(tc.mockContent))
+			tc.expectedAssertFunc(t, err)
+			assert.Equal(t, tc.expected, res)
+		})
+	}
+}
+
+func TestCheckpoint_Save(t *testing.T) {
+	testCases := []struct {
+		name           string
+		mockCheckpoint *Checkpoint
+	}{
+		{"empty", &Checkpoint{}},
+		{
+			"regular",
+			&Checkpoint{
+				Frontier: []*domain.Target{{BaseURL: "https://www.save.com/about", Depth: 1, Parent: "https://www.save.com"}},
+				Seen:     []string{"https://www.save.com", "https://www.save.com/about"},
+				SiteMap:  []string{"https://www.save.com"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "checkpoint")
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			defer os.RemoveAll(dir)
+
+			assert.Nil(t, Save(dir, &Checkpoint{Seen: []string{"https://www.overwritten.com"}}))
+			assert.Nil(t, Save(dir, tc.mockCheckpoint))
+
+			res, err := Load(dir)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.mockCheckpoint, res)
+
+			files, err := ioutil.ReadDir(dir)
+			assert.Nil(t, err)
+			assert.Len(t, files, 1)
+		})
+	}
+}
+
+func TestCheckpoint_Load(t *testing.T) {
+	testCases := []struct {
+		name               string
+		mockDir            string
+		expectedAssertFunc func(assert.TestingT, interface{}, ...interface{}) bool
+	}{
+		{"notFound", "testdata/not-found", assert.NotNil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Load(tc.mockDir)
+			tc.expectedAssertFunc(t, err)
+		})
+	}
+}
@@ -0,0 +1,446 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/timtosi/mcrawler/internal"
+	"github.com/timtosi/mcrawler/internal/checkpoint"
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+// stage is the name used by `*cluster.Node` to report errors and notify
+// events.
+const stage = "cluster"
+
+// Local is an `interface` representing the `*crawler.Crawler` running next to
+// a `*cluster.Node`.
+type Local interface {
+	Inject(...*domain.Target)
+	Idle() bool
+}
+
+// Status is a `struct` representing the state of a `*cluster.Node` as seen by
+// the coordinator of the cluster. `Dirty` is `true` if `*domain.Target`s have
+// been received since the previous `Status`.
+type Status struct {
+	Idle     bool  `json:"idle"`
+	Dirty    bool  `json:"dirty"`
+	Sent     int64 `json:"sent"`
+	Received int64 `json:"received"`
+}
+
+// Node is a `struct` representing a process of a crawl shared between
+// several processes. Every host is owned by one of the `peers`, chosen with a
+// hash of its name, and `*domain.Target`s are forwarded to their owner.
+//
+// The first peer coordinates the cluster: it periodically collects the
+// `cluster.Status` of every peer and tells them to stop once two consecutive
+// waves found them all idle with nothing received in between.
+type Node struct {
+	internal.Reporter
+
+	self       int
+	peers      []string
+	client     *http.Client
+	probeEvery time.Duration
+	local      Local
+
+	sent     int64
+	received int64
+	dirty    bool
+
+	done       chan struct{}
+	doneOnce   sync.Once
+	detectOnce sync.Once
+	stop       chan struct{}
+	stopOnce   sync.Once
+	mu         *sync.Mutex
+}
+
+// NewNode returns a new `*cluster.Node` being the `self`th of `peers`, which
+// are the base URLs at which the `cluster.Node.Handler` of every process is
+// served, or an `error` if `self` is not a valid index of `peers`. It can be
+// configured through `opts` functions.
+func NewNode(self int, peers []string, opts ...func(*Node)) (*Node, error) {
+	if self < 0 || self >= len(peers) {
+		return nil, fmt.Errorf("NewNode: node %d not found in %d peers", self, len(peers))
+	}
+
+	n := &Node{
+		self:       self,
+		peers:      peers,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		probeEvery: 200 * time.Millisecond,
+		done:       make(chan struct{}),
+		stop:       make(chan struct{}),
+		mu:         &sync.Mutex{},
+	}
+
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n, nil
+}
+
+// WithProbeInterval makes the coordinator of a cluster collect the
+// `cluster.Status` of every peer each `d` period of time.
+func WithProbeInterval(d time.Duration) func(*Node) {
+	return func(n *Node) { n.probeEvery = d }
+}
+
+// Owner returns the index of the peer owning the host of `link`, or an
+// `error` if `link` cannot be parsed properly by `url.Parse`.
+func (n *Node) Owner(link string) (int, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return 0, fmt.Errorf("Owner: %v", err)
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(u.Host))
+	return int(h.Sum32() % uint32(len(n.peers))), nil
+}
+
+// Owns returns `true` if the host of `link` is owned by `n`.
+func (n *Node) Owns(link string) bool {
+	owner, err := n.Owner(link)
+	return err == nil && owner == n.self
+}
+
+// Handler returns the `http.Handler` through which `n` receives
+// `*domain.Target`s, which are given to `local`, and messages from the
+// coordinator of the cluster.
+func (n *Node) Handler(local Local) http.Handler {
+	n.local = local
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/targets", n.handleTargets)
+	mux.HandleFunc("/status", n.handleStatus)
+	mux.HandleFunc("/stop", n.handleStop)
+	return mux
+}
+
+// handleTargets gives the `*domain.Target`s found in the body of `r` to
+// `n.local`. They are encoded as the frontier of a `*checkpoint.Checkpoint`.
+func (n *Node) handleTargets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	cp, err := checkpoint.Read(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	n.mu.Lock()
+	n.received += int64(len(cp.Frontier))
+	n.dirty = true
+	n.mu.Unlock()
+
+	n.local.Inject(cp.Frontier...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleStatus writes the `cluster.Status` of `n` as JSON and resets its
+// `Dirty` flag.
+func (n *Node) handleStatus(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	s := Status{Idle: n.local.Idle(), Dirty: n.dirty, Sent: n.sent, Received: n.received}
+	n.dirty = false
+	n.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s)
+}
+
+// handleStop makes `n` done.
+func (n *Node) handleStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	n.doneOnce.Do(func() { close(n.done) })
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Done implements the `crawler.Terminator` interface. The returned channel is
+// closed once the crawl is over on every peer.
+//
+// NOTE: Calling `Done` on the coordinator of the cluster starts collecting
+// the `cluster.Status` of every peer.
+func (n *Node) Done() <-chan struct{} {
+	if n.self == 0 {
+		n.detectOnce.Do(func() { go n.detect() })
+	}
+	return n.done
+}
+
+// Close stops `n` from collecting the `cluster.Status` of every peer.
+func (n *Node) Close() {
+	n.stopOnce.Do(func() { close(n.stop) })
+}
+
+// status returns the `cluster.Status` of the `peer`th peer or an `error` if
+// something bad occurs.
+func (n *Node) status(peer int) (*Status, error) {
+	resp, err := n.client.Get(n.peers[peer] + "/status")
+	if err != nil {
+		return nil, fmt.Errorf("status: %v", err)
+	}
+	defer resp.Body.Close()
+
+	s := &Status{}
+	if err := json.NewDecoder(resp.Body).Decode(s); err != nil {
+		return nil, fmt.Errorf("status: %v", err)
+	}
+	return s, nil
+}
+
+// wave collects the `cluster.Status` of every peer. It returns whether they
+// are all idle with as many `*domain.Target`s sent as received, and whether
+// any of them received `*domain.Target`s since the previous wave.
+func (n *Node) wave() (bool, bool) {
+	var sent, received int64
+	idle, dirty := true, false
+
+	for peer := range n.peers {
+		s, err := n.status(peer)
+		if err != nil {
+			return false, true
+		}
+		idle = idle && s.Idle
+		dirty = dirty || s.Dirty
+		sent += s.Sent
+		received += s.Received
+	}
+	return idle && sent == received, dirty
+}
+
+// detect runs waves until the crawl is over on every peer, then tells every
+// peer to stop.
+//
+// NOTE: This function will loop until `n.stop` is closed or the crawl is over.
+func (n *Node) detect() {
+	ticker := time.NewTicker(n.probeEvery)
+	defer ticker.Stop()
+
+	previous := false
+	for {
+		select {
+		case <-ticker.C:
+		case <-n.stop:
+			return
+		}
+
+		idle, dirty := n.wave()
+		if idle && previous && !dirty {
+			break
+		}
+		previous = idle
+	}
+
+	for peer := range n.peers {
+		if peer == n.self {
+			continue
+		}
+		resp, err := n.client.Post(n.peers[peer]+"/stop", "text/plain", nil)
+		if err != nil {
+			log.Printf("Node: %v", err)
+			continue
+		}
+		resp.Body.Close()
+	}
+	n.doneOnce.Do(func() { close(n.done) })
+}
+
+// maxBatch is the maximum number of `*domain.Target`s forwarded to a peer in
+// a single request.
+const maxBatch = 100
+
+// peerQueue is a `struct` representing the `*domain.Target`s waiting to be
+// forwarded to a peer.
+type peerQueue struct {
+	targets []*domain.Target
+	closed  bool
+	wake    chan struct{}
+	mu      *sync.Mutex
+}
+
+// newPeerQueue returns a new `*cluster.peerQueue`.
+func newPeerQueue() *peerQueue {
+	return &peerQueue{wake: make(chan struct{}, 1), mu: &sync.Mutex{}}
+}
+
+// signal wakes `q.take` up.
+func (q *peerQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// push adds `t` to `q` without blocking.
+//
+// NOTE: This function is thread-safe.
+func (q *peerQueue) push(t *domain.Target) {
+	q.mu.Lock()
+	q.targets = append(q.targets, t)
+	q.mu.Unlock()
+	q.signal()
+}
+
+// close makes `q.take` return `false` once `q` is empty.
+//
+// NOTE: This function is thread-safe.
+func (q *peerQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.signal()
+}
+
+// take returns at most `cluster.maxBatch` of the `*domain.Target`s waiting in
+// `q`, waiting for one if there is none, or `false` if `q` is empty and
+// closed.
+//
+// NOTE: This function is thread-safe.
+func (q *peerQueue) take() ([]*domain.Target, bool) {
+	for {
+		q.mu.Lock()
+		if n := len(q.targets); n != 0 {
+			if n > maxBatch {
+				n = maxBatch
+			}
+			batch := q.targets[:n:n]
+			q.targets = q.targets[n:]
+			q.mu.Unlock()
+			return batch, true
+		}
+		closed := q.closed
+		q.mu.Unlock()
+
+		if closed {
+			return nil, false
+		}
+		<-q.wake
+	}
+}
+
+// forward sends `batch` to the `peer`th peer or returns an `error` if
+// something bad occurs.
+func (n *Node) forward(ctx context.Context, peer int, batch []*domain.Target) error {
+	var buf bytes.Buffer
+	if err := (&checkpoint.Checkpoint{Frontier: batch}).Write(&buf); err != nil {
+		return fmt.Errorf("forward: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, n.peers[peer]+"/targets", &buf)
+	if err != nil {
+		return fmt.Errorf("forward: %v", err)
+	}
+
+	resp, err := n.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("forward: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("forward: unexpected status %s", resp.Status)
+	}
+
+	n.mu.Lock()
+	n.sent += int64(len(batch))
+	n.mu.Unlock()
+	return nil
+}
+
+// sendTo forwards in batches the `*domain.Target`s of `q` to the `peer`th
+// peer. The ones that cannot be forwarded are reported as
+// `*internal.CrawlError`s and sent to `out` instead, so that they are not
+// lost.
+//
+// NOTE: This function will loop until `q` is closed and empty.
+func (n *Node) sendTo(ctx context.Context, wg *sync.WaitGroup, peer int, q *peerQueue, out chan<- *domain.Target) {
+	for {
+		batch, ok := q.take()
+		if !ok {
+			return
+		}
+
+		err := n.forward(ctx, peer, batch)
+		for _, t := range batch {
+			if err == nil {
+				n.NotifyDiscard(stage, t, internal.ReasonForwarded)
+				wg.Done()
+				continue
+			}
+			if ctx.Err() == nil {
+				n.ReportError(stage, t.BaseURL, err)
+			}
+			n.NotifyLeave(stage, t)
+			out <- t
+		}
+	}
+}
+
+// Pipe connects `in` and `out` together. Any `*domain.Target` received from
+// `in` will be sent to `out` if its host is owned by `n`, or forwarded to its
+// owner otherwise.
+//
+// NOTE: `*domain.Target`s are forwarded in batches by one goroutine per peer,
+// so that a slow or unreachable peer never blocks the pipeline. The ones that
+// cannot be forwarded are reported as `*internal.CrawlError`s and sent to
+// `out` instead, so that they are not lost.
+//
+// NOTE: This function will loop over a channel until `in` is closed. After that
+// it will close `out` once every `*domain.Target` has been forwarded.
+func (n *Node) Pipe(ctx context.Context, wg *sync.WaitGroup, in <-chan *domain.Target, out chan<- *domain.Target) {
+	defer close(out)
+
+	queues := make([]*peerQueue, len(n.peers))
+	senders := sync.WaitGroup{}
+	defer senders.Wait()
+	for peer := range n.peers {
+		if peer == n.self {
+			continue
+		}
+		queues[peer] = newPeerQueue()
+		defer queues[peer].close()
+
+		senders.Add(1)
+		go func(peer int) {
+			defer senders.Done()
+			n.sendTo(ctx, wg, peer, queues[peer], out)
+		}(peer)
+	}
+
+	for t := range in {
+		n.NotifyEnter(stage, t)
+		if ctx.Err() != nil {
+			n.NotifyDiscard(stage, t, internal.ReasonCancelled)
+			wg.Done()
+			continue
+		}
+
+		if owner, err := n.Owner(t.BaseURL); err == nil && owner != n.self {
+			queues[owner].push(t)
+			continue
+		}
+
+		n.NotifyLeave(stage, t)
+		out <- t
+	}
+}
@@ -0,0 +1,288 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timtosi/mcrawler/internal"
+	"github.com/timtosi/mcrawler/internal/crawler"
+	"github.com/timtosi/mcrawler/internal/domain"
+	"github.com/timtosi/mcrawler/internal/extractor"
+	"github.com/timtosi/mcrawler/internal/mapper"
+)
+
+// mockLocal is a `struct` only used for test purposes. It implements the
+// `cluster.Local` interface.
+type mockLocal struct {
+	injected []*domain.Target
+	idle     bool
+	mu       sync.Mutex
+}
+
+// Inject implements the `cluster.Local` interface.
+func (ml *mockLocal) Inject(ts ...*domain.Target) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	ml.injected = append(ml.injected, ts...)
+}
+
+// Idle implements the `cluster.Local` interface.
+func (ml *mockLocal) Idle() bool {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	return ml.idle
+}
+
+func TestNode_NewNode(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mockSelf    int
+		mockPeers   []string
+		expectedErr bool
+	}{
+		{"regular", 1, []string{"http://a:1", "http://a:2"}, false},
+		{"negative", -1, []string{"http://a:1"}, true},
+		{"outOfRange", 1, []string{"http://a:1"}, true},
+		{"noPeer", 0, nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewNode(tc.mockSelf, tc.mockPeers)
+			assert.Equal(t, tc.expectedErr, err != nil)
+		})
+	}
+}
+
+func TestNode_Owner(t *testing.T) {
+	n, err := NewNode(0, []string{"http://a:1", "http://a:2", "http://a:3"})
+	assert.Nil(t, err)
+
+	owners := make(map[int]bool)
+	for i := 0; i < 30; i++ {
+		owner, err := n.Owner(fmt.Sprintf("http://host%d.com/page", i))
+		assert.Nil(t, err)
+		assert.True(t, owner >= 0 && owner < 3)
+		owners[owner] = true
+
+		again, _ := n.Owner(fmt.Sprintf("http://host%d.com/other", i))
+		assert.Equal(t, owner, again)
+	}
+	assert.Len(t, owners, 3)
+}
+
+func TestNode_Handler(t *testing.T) {
+	ml := &mockLocal{idle: true}
+	n, err := NewNode(0, []string{"http://unused"})
+	assert.Nil(t, err)
+	ms := httptest.NewServer(n.Handler(ml))
+	defer ms.Close()
+	n.peers[0] = ms.URL
+
+	s, err := n.status(0)
+	assert.Nil(t, err)
+	assert.Equal(t, &Status{Idle: true}, s)
+
+	tgt := domain.NewChildTarget("http://www.a.com/b", domain.NewTarget("http://www.a.com"))
+	assert.Nil(t, n.forward(context.Background(), 0, []*domain.Target{tgt}))
+	assert.Equal(t, []*domain.Target{tgt}, ml.injected)
+
+	s, err = n.status(0)
+	assert.Nil(t, err)
+	assert.Equal(t, &Status{Idle: true, Dirty: true, Sent: 1, Received: 1}, s)
+
+	s, err = n.status(0)
+	assert.Nil(t, err)
+	assert.False(t, s.Dirty)
+
+	resp, err := http.Post(ms.URL+"/stop", "text/plain", nil)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	select {
+	case <-n.done:
+	case <-time.After(time.Second):
+		t.Errorf("TestNode_Handler: node not stopped")
+	}
+}
+
+func TestNode_Pipe(t *testing.T) {
+	const nodes = 3
+
+	hits := make(map[string]int)
+	hitsMu := sync.Mutex{}
+	var sites []*httptest.Server
+	for i := 0; i < 6; i++ {
+		next := i + 1
+		site := httptest.NewServer(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hitsMu.Lock()
+				hits[r.Host+r.URL.Path]++
+				hitsMu.Unlock()
+
+				switch r.URL.Path {
+				case "/":
+					w.Write([]byte(`<a href="/p1">p1</a><a href="/p2">p2</a>`))
+				case "/p1":
+					if next < len(sites) {
+						w.Write([]byte(`<a href="` + sites[next].URL + `/">next</a>`))
+					}
+				}
+			}),
+		)
+		defer site.Close()
+		sites = append(sites, site)
+	}
+
+	var siteURLs []string
+	for _, site := range sites {
+		siteURLs = append(siteURLs, site.URL)
+	}
+
+	var peers []string
+	var handlers []http.Handler
+	for i := 0; i < nodes; i++ {
+		i := i
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers[i].ServeHTTP(w, r)
+		}))
+		defer srv.Close()
+		peers = append(peers, srv.URL)
+	}
+	handlers = make([]http.Handler, nodes)
+
+	var crawlers []*crawler.Crawler
+	var nodeList []*Node
+	for i := 0; i < nodes; i++ {
+		n, err := NewNode(i, peers, WithProbeInterval(10*time.Millisecond))
+		assert.Nil(t, err)
+		defer n.Close()
+
+		c := crawler.NewCrawler(crawler.WithTerminator(n))
+		handlers[i] = n.Handler(c)
+		crawlers = append(crawlers, c)
+		nodeList = append(nodeList, n)
+	}
+
+	var mappers []*mapper.Mapper
+	errs := make(chan error, nodes)
+	for i, n := range nodeList {
+		c := crawlers[i]
+
+		var seeds []*domain.Target
+		if n.Owns(sites[0].URL + "/") {
+			seeds = append(seeds, domain.NewTarget(sites[0].URL+"/"))
+		}
+
+		f, err := internal.NewFollower(siteURLs...)
+		assert.Nil(t, err)
+		m := mapper.NewMapper()
+		mappers = append(mappers, m)
+
+		go func() {
+			errs <- c.Run(
+				context.Background(),
+				seeds,
+				internal.NewArchiver(),
+				f,
+				internal.NewWorker(),
+				m,
+				extractor.NewExtractor([]extractor.CheckFunc{extractor.GetLinkNoFollow}),
+				n,
+			)
+		}()
+	}
+
+	for i := 0; i < nodes; i++ {
+		select {
+		case err := <-errs:
+			assert.Nil(t, err)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("TestNode_Pipe: cluster did not terminate")
+		}
+	}
+
+	var expected, crawled []string
+	for _, site := range sites {
+		expected = append(expected, site.URL+"/", site.URL+"/p1", site.URL+"/p2")
+	}
+	for i, m := range mappers {
+		for _, link := range m.SiteMap() {
+			assert.True(t, nodeList[i].Owns(link), link)
+			crawled = append(crawled, link)
+		}
+	}
+	assert.ElementsMatch(t, expected, crawled)
+	for page, count := range hits {
+		assert.Equal(t, 1, count, page)
+	}
+}
+
+func TestNode_Pipe_slowPeer(t *testing.T) {
+	release := make(chan struct{})
+	ml := &mockLocal{}
+	var peerHandler http.Handler
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		peerHandler.ServeHTTP(w, r)
+	}))
+	defer slow.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	n, err := NewNode(0, []string{"http://self", slow.URL, down.URL})
+	assert.Nil(t, err)
+	peer, err := NewNode(1, []string{"http://self", slow.URL, down.URL})
+	assert.Nil(t, err)
+	peerHandler = peer.Handler(ml)
+
+	// Find a link owned by every node.
+	links := make([]string, 3)
+	for i := 0; links[0] == "" || links[1] == "" || links[2] == ""; i++ {
+		link := fmt.Sprintf("http://host-%d.com/", i)
+		owner, err := n.Owner(link)
+		assert.Nil(t, err)
+		if links[owner] == "" {
+			links[owner] = link
+		}
+	}
+
+	inChan := make(chan *domain.Target)
+	outChan := make(chan *domain.Target)
+	wg := sync.WaitGroup{}
+	wg.Add(3)
+	go n.Pipe(context.Background(), &wg, inChan, outChan)
+
+	inChan <- domain.NewTarget(links[1])
+	inChan <- domain.NewTarget(links[0])
+	select {
+	case res := <-outChan:
+		assert.Equal(t, links[0], res.BaseURL)
+		wg.Done()
+	case <-time.After(time.Second):
+		t.Fatalf("TestNode_Pipe_slowPeer: pipe blocked by a slow peer")
+	}
+
+	inChan <- domain.NewTarget(links[2])
+	res := <-outChan
+	assert.Equal(t, links[2], res.BaseURL)
+	wg.Done()
+
+	close(release)
+	close(inChan)
+	_, ok := <-outChan
+	assert.False(t, ok)
+	wg.Wait()
+
+	assert.Len(t, ml.injected, 1)
+	assert.Equal(t, links[1], ml.injected[0].BaseURL)
+}
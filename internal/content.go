@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// HTMLContentTypes are the media types of HTML web pages, those whose links
+// can be extracted.
+var HTMLContentTypes = []string{"text/html", "application/xhtml+xml"}
+
+// acceptContentType returns `true` if the `Content-Type` of `header` matches
+// one of `allowed`, either exactly (`text/html`) or by its type (`text/*`).
+// Every media type is accepted when `allowed` is empty, as well as responses
+// without `Content-Type`.
+func acceptContentType(allowed []string, header http.Header) bool {
+	value := header.Get("Content-Type")
+	if len(allowed) == 0 || len(value) == 0 {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return false
+	}
+	for _, a := range allowed {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if a == mediaType || (strings.HasSuffix(a, "/*") && strings.HasPrefix(mediaType, a[:len(a)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// looksHTML returns `false` if the extension of the path of `link` is one of
+// the fixed `internal.assetExtensions`, e.g. `.png` or `.pdf`. Unknown and
+// dynamic extensions, e.g. `.php`, `.asp` or `.xml`, may be HTML and return
+// `true`.
+func looksHTML(link string) bool {
+	return !isAsset(link)
+}
+
+// readBody reads `r` until EOF or until `max` bytes have been read, in which
+// case it also returns `true`. `r` is read entirely when `max` is lower than
+// 1.
+func readBody(r io.Reader, max int64) ([]byte, bool, error) {
+	if max < 1 {
+		content, err := ioutil.ReadAll(r)
+		return content, false, err
+	}
+
+	content, err := ioutil.ReadAll(io.LimitReader(r, max+1))
+	if int64(len(content)) > max {
+		return content[:max], true, err
+	}
+	return content, false, err
+}
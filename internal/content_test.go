@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptContentType(t *testing.T) {
+	testCases := []struct {
+		name           string
+		mockAllowed    []string
+		mockType       string
+		expectedAccept bool
+	}{
+		{"noAllowlist", nil, "image/png", true},
+		{"noContentType", HTMLContentTypes, "", true},
+		{"exact", HTMLContentTypes, "text/html; charset=utf-8", true},
+		{"xhtml", HTMLContentTypes, "application/xhtml+xml", true},
+		{"rejected", HTMLContentTypes, "image/png", false},
+		{"wildcard", []string{"image/*"}, "image/png", true},
+		{"wildcardRejected", []string{"image/*"}, "text/html", false},
+		{"caseInsensitive", []string{" Text/HTML "}, "TEXT/html", true},
+		{"malformed", HTMLContentTypes, "text/html; =", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := http.Header{}
+			if len(tc.mockType) != 0 {
+				h.Set("Content-Type", tc.mockType)
+			}
+			assert.Equal(t, tc.expectedAccept, acceptContentType(tc.mockAllowed, h))
+		})
+	}
+}
+
+func TestLooksHTML(t *testing.T) {
+	for link, expected := range map[string]bool{
+		"http://a.com":             true,
+		"http://a.com/":            true,
+		"http://a.com/page":        true,
+		"http://a.com/index.html":  true,
+		"http://a.com/logo.png":    false,
+		"http://a.com/doc.pdf?v=1": false,
+		"http://a.com/%zz.png":     true,
+		"http://a.com/index.php":   true,
+		"http://a.com/default.asp": true,
+		"http://a.com/page.jsp":    true,
+		"http://a.com/feed.xml":    true,
+	} {
+		assert.Equal(t, expected, looksHTML(link), link)
+	}
+}
+
+func TestReadBody(t *testing.T) {
+	testCases := []struct {
+		name              string
+		mockMax           int64
+		expectedContent   string
+		expectedTruncated bool
+	}{
+		{"noLimit", 0, "0123456789", false},
+		{"underLimit", 20, "0123456789", false},
+		{"atLimit", 10, "0123456789", false},
+		{"overLimit", 4, "0123", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			content, truncated, err := readBody(bytes.NewBufferString("0123456789"), tc.mockMax)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedContent, string(content))
+			assert.Equal(t, tc.expectedTruncated, truncated)
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/timtosi/mcrawler/internal"
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+// fetchStage is the name of the stage of the `*internal.Worker`, whose
+// `internal.Event`s are used to track pages being fetched.
+const fetchStage = "worker"
+
+// Crawl is an `interface` representing the running `*crawler.Crawler` a
+// `*control.Server` controls.
+type Crawl interface {
+	Pause()
+	Resume()
+	Paused() bool
+	Stop()
+	Seed(...*domain.Target) error
+	FrontierLen() int
+}
+
+// Status is a `struct` representing the state of a crawl, as served by
+// `GET /status`. `Done` is the number of pages fetched and `Hosts` the hosts
+// with a request in flight.
+type Status struct {
+	Frontier int      `json:"frontier"`
+	Done     int      `json:"done"`
+	Hosts    []string `json:"hosts"`
+	Paused   bool     `json:"paused"`
+}
+
+// Server is a `struct` keeping track of a crawl. Its `control.Server.Handler`
+// serves an HTTP API controlling this crawl:
+//
+//	GET  /status  returns the `control.Status` of the crawl as JSON.
+//	POST /pause   stops sending pages from the frontier to the pipeline.
+//	POST /resume  sends pages from the frontier to the pipeline again.
+//	POST /seed    adds the URLs of the body, one per line, to the crawl, or
+//	              answers `409 Conflict` once the crawl is over.
+//	POST /stop    finishes the crawl gracefully.
+//
+// It also implements the `internal.Observer` interface and must be given to
+// `crawler.WithObserver` so that its `control.Status` is kept up to date.
+type Server struct {
+	done  int
+	hosts map[string]int
+	mu    *sync.Mutex
+}
+
+// NewServer returns a new `*control.Server`.
+func NewServer() *Server {
+	return &Server{
+		hosts: make(map[string]int),
+		mu:    &sync.Mutex{},
+	}
+}
+
+// Handler returns the `http.Handler` serving the API controlling `crawl`.
+func (s *Server) Handler(crawl Crawl) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.handleStatus(crawl))
+	mux.HandleFunc("/pause", post(crawl.Pause))
+	mux.HandleFunc("/resume", post(crawl.Resume))
+	mux.HandleFunc("/stop", post(crawl.Stop))
+	mux.HandleFunc("/seed", handleSeed(crawl))
+	return mux
+}
+
+// Observe implements the `internal.Observer` interface.
+//
+// NOTE: This function is thread-safe.
+func (s *Server) Observe(e internal.Event) {
+	if e.Stage != fetchStage {
+		return
+	}
+
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch e.Kind {
+	case internal.EventRequest:
+		s.hosts[u.Host]++
+	case internal.EventFetch:
+		if s.hosts[u.Host]--; s.hosts[u.Host] <= 0 {
+			delete(s.hosts, u.Host)
+		}
+	case internal.EventLeave:
+		s.done++
+	}
+}
+
+// Status returns the current `control.Status` of `crawl`.
+//
+// NOTE: This function is thread-safe.
+func (s *Server) Status(crawl Crawl) *Status {
+	s.mu.Lock()
+	st := &Status{Done: s.done, Hosts: make([]string, 0, len(s.hosts))}
+	for host := range s.hosts {
+		st.Hosts = append(st.Hosts, host)
+	}
+	s.mu.Unlock()
+
+	sort.Strings(st.Hosts)
+	st.Frontier = crawl.FrontierLen()
+	st.Paused = crawl.Paused()
+	return st
+}
+
+// handleStatus returns an `http.HandlerFunc` writing the `control.Status` of
+// `crawl` as JSON.
+func (s *Server) handleStatus(crawl Crawl) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Status(crawl))
+	}
+}
+
+// post returns an `http.HandlerFunc` calling `action` on `POST` requests.
+func post(action func()) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		action()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleSeed returns an `http.HandlerFunc` adding every URL found in the
+// request body, one per line, to `crawl`. No URL is added if any of them is
+// not an absolute URL.
+//
+// NOTE: Blank lines and lines starting with `#` are ignored.
+func handleSeed(crawl Crawl) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var seeds []*domain.Target
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if len(line) == 0 || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			if u, err := url.Parse(line); err != nil || !u.IsAbs() || len(u.Host) == 0 {
+				http.Error(w, fmt.Sprintf("invalid URL %q", line), http.StatusBadRequest)
+				return
+			}
+			seeds = append(seeds, domain.NewTarget(line))
+		}
+		if err := scanner.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := crawl.Seed(seeds...); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,119 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timtosi/mcrawler/internal"
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+// mockCrawl is a `struct` only used for test purposes. It implements the
+// `control.Crawl` interface by recording the calls it receives.
+type mockCrawl struct {
+	calls    []string
+	injected []string
+	paused   bool
+	finished bool
+}
+
+func (mc *mockCrawl) Pause()           { mc.calls = append(mc.calls, "pause"); mc.paused = true }
+func (mc *mockCrawl) Resume()          { mc.calls = append(mc.calls, "resume"); mc.paused = false }
+func (mc *mockCrawl) Paused() bool     { return mc.paused }
+func (mc *mockCrawl) Stop()            { mc.calls = append(mc.calls, "stop") }
+func (mc *mockCrawl) FrontierLen() int { return 42 }
+func (mc *mockCrawl) Seed(targets ...*domain.Target) error {
+	if mc.finished {
+		return errors.New("crawl finished")
+	}
+	mc.calls = append(mc.calls, "inject")
+	for _, t := range targets {
+		mc.injected = append(mc.injected, t.BaseURL)
+	}
+	return nil
+}
+
+func TestServer_Handler(t *testing.T) {
+	testCases := []struct {
+		name             string
+		mockMethod       string
+		mockPath         string
+		mockBody         string
+		mockFinished     bool
+		expectedCode     int
+		expectedCalls    []string
+		expectedInjected []string
+	}{
+		{"pause", http.MethodPost, "/pause", "", false, http.StatusNoContent, []string{"pause"}, nil},
+		{"resume", http.MethodPost, "/resume", "", false, http.StatusNoContent, []string{"resume"}, nil},
+		{"stop", http.MethodPost, "/stop", "", false, http.StatusNoContent, []string{"stop"}, nil},
+		{"getPause", http.MethodGet, "/pause", "", false, http.StatusMethodNotAllowed, nil, nil},
+		{"postStatus", http.MethodPost, "/status", "", false, http.StatusMethodNotAllowed, nil, nil},
+		{"notFound", http.MethodGet, "/unknown", "", false, http.StatusNotFound, nil, nil},
+		{
+			"seed",
+			http.MethodPost,
+			"/seed",
+			"http://a.com\n\n# comment\n  http://b.com/page  \n",
+			false,
+			http.StatusNoContent,
+			[]string{"inject"},
+			[]string{"http://a.com", "http://b.com/page"},
+		},
+		{"seedInvalid", http.MethodPost, "/seed", "http://a.com\n/relative\n", false, http.StatusBadRequest, nil, nil},
+		{"seedFinished", http.MethodPost, "/seed", "http://a.com\n", true, http.StatusConflict, nil, nil},
+		{"getSeed", http.MethodGet, "/seed", "", false, http.StatusMethodNotAllowed, nil, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &mockCrawl{finished: tc.mockFinished}
+			rec := httptest.NewRecorder()
+			NewServer().Handler(mc).ServeHTTP(rec, httptest.NewRequest(tc.mockMethod, tc.mockPath, strings.NewReader(tc.mockBody)))
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			assert.Equal(t, tc.expectedCalls, mc.calls)
+			assert.Equal(t, tc.expectedInjected, mc.injected)
+		})
+	}
+}
+
+func TestServer_Status(t *testing.T) {
+	mc := &mockCrawl{paused: true}
+	s := NewServer()
+
+	for _, e := range []internal.Event{
+		{Kind: internal.EventEnter, Stage: "worker", URL: "http://a.com/1"},
+		{Kind: internal.EventEnter, Stage: "worker", URL: "http://a.com/2"},
+		{Kind: internal.EventEnter, Stage: "worker", URL: "http://b.com/1"},
+		{Kind: internal.EventEnter, Stage: "worker", URL: "http://c.com/1"},
+		{Kind: internal.EventEnter, Stage: "worker", URL: "http://e.com/1"},
+		{Kind: internal.EventRequest, Stage: "worker", URL: "http://a.com/1"},
+		{Kind: internal.EventRequest, Stage: "worker", URL: "http://a.com/2"},
+		{Kind: internal.EventRequest, Stage: "worker", URL: "http://b.com/1"},
+		{Kind: internal.EventRequest, Stage: "worker", URL: "http://c.com/1"},
+		{Kind: internal.EventRequest, Stage: "archiver", URL: "http://d.com/1"},
+		{Kind: internal.EventFetch, Stage: "worker", URL: "http://a.com/1", Status: 200},
+		{Kind: internal.EventLeave, Stage: "worker", URL: "http://a.com/1"},
+		{Kind: internal.EventFetch, Stage: "worker", URL: "http://b.com/1", Status: 200},
+		{Kind: internal.EventLeave, Stage: "worker", URL: "http://b.com/1"},
+		{Kind: internal.EventFetch, Stage: "worker", URL: "http://c.com/1"},
+		{Kind: internal.EventDiscard, Stage: "worker", URL: "http://c.com/1", Reason: internal.ReasonFetchFailed},
+	} {
+		s.Observe(e)
+	}
+
+	rec := httptest.NewRecorder()
+	s.Handler(mc).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	st := &Status{}
+	assert.Nil(t, json.NewDecoder(rec.Body).Decode(st))
+	assert.Equal(t, &Status{Frontier: 42, Done: 2, Hosts: []string{"a.com"}, Paused: true}, st)
+}
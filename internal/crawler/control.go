@@ -0,0 +1,96 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+// ErrFinished is the `error` returned by `Crawler.Seed` once the crawl is
+// over or draining, since new `*domain.Target`s would never be crawled.
+var ErrFinished = errors.New("crawl finished")
+
+// Pause makes `c` stop sending `*domain.Target`s from its frontier to the
+// pipeline until `c.Resume` is called. `*domain.Target`s already in the
+// pipeline are still processed.
+//
+// NOTE: A paused crawl is resumed as soon as it is cancelled or stopped, so
+// that it can be drained.
+//
+// NOTE: This function is thread-safe.
+func (c *Crawler) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.paused = true
+}
+
+// Resume makes `c` send `*domain.Target`s from its frontier to the pipeline
+// again after a call to `c.Pause`.
+//
+// NOTE: This function is thread-safe.
+func (c *Crawler) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.paused = false
+	c.cond.Broadcast()
+}
+
+// Paused returns `true` if `c` has been paused by `c.Pause`.
+//
+// NOTE: This function is thread-safe.
+func (c *Crawler) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.paused
+}
+
+// Stop makes `c.Run` finish gracefully: `*domain.Target`s still in the
+// pipeline are drained and `c.Run` returns without any `error`. It does
+// nothing if `c.Run` is not running.
+//
+// NOTE: Like a cancelled crawl, a stopped crawl saves the `*domain.Target`s
+// left in its frontier in its last checkpoint so that it can be resumed.
+//
+// NOTE: This function is thread-safe.
+func (c *Crawler) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cancel != nil {
+		c.stopped = true
+		c.cancel()
+	}
+}
+
+// Seed adds `targets` to the crawl like `c.Inject`, or returns
+// `crawler.ErrFinished` if `c.Run` has returned or is draining the pipeline
+// because it has been cancelled or stopped.
+//
+// NOTE: This function is thread-safe.
+func (c *Crawler) Seed(targets ...*domain.Target) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrFinished
+	} else if c.runDone != nil {
+		select {
+		case <-c.runDone:
+			return ErrFinished
+		default:
+		}
+	}
+	c.inject(targets)
+	return nil
+}
+
+// resumeOnDone resumes `c` once `ctx` is done, so that the `*domain.Target`s
+// left in its frontier can be drained.
+func (c *Crawler) resumeOnDone(ctx context.Context) {
+	<-ctx.Done()
+	c.Resume()
+}
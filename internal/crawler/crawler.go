@@ -1,59 +1,480 @@
 package crawler
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/timtosi/mcrawler/internal"
+	"github.com/timtosi/mcrawler/internal/checkpoint"
 	"github.com/timtosi/mcrawler/internal/domain"
+	"github.com/timtosi/mcrawler/internal/mapper"
 )
 
 // Crawler is a `struct` that crawls a website.
 type Crawler struct {
-	urlFrontier chan *domain.Target
+	urlFrontier Frontier
+	budget      *internal.Budget
+
+	checkpointDir   string
+	checkpointEvery time.Duration
+	archiver        *internal.Archiver
+	mapper          *mapper.Mapper
+	handed          map[handedKey]*domain.Target
+
+	observers []internal.Observer
+
+	terminator Terminator
+	inbox      []*domain.Target
+	wake       chan struct{}
+	idle       bool
+
+	paused  bool
+	stopped bool
+	cancel  context.CancelFunc
+	runDone <-chan struct{}
+
+	errs   *ErrorSummary
+	closed bool
+	mu     *sync.Mutex
+	cond   *sync.Cond
+}
+
+// NewCrawler returns a new `*crawler.Crawler` that can be configured through
+// `opts` functions. By default, web pages are crawled breadth-first through a
+// `*crawler.FIFOFrontier`.
+func NewCrawler(opts ...func(*Crawler)) *Crawler {
+	mu := &sync.Mutex{}
+	c := &Crawler{
+		urlFrontier: NewFIFOFrontier(),
+		handed:      make(map[handedKey]*domain.Target),
+		errs:        newErrorSummary(nil),
+		wake:        make(chan struct{}, 1),
+		mu:          mu,
+		cond:        sync.NewCond(mu),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithFrontier makes a `*crawler.Crawler` use `f` to decide in which order
+// web pages are crawled.
+//
+// NOTE: Any `*domain.Target` already in `f` is crawled along with the seeds
+// given to `Crawler.Run`, which allows to resume a crawl.
+func WithFrontier(f Frontier) func(*Crawler) {
+	return func(c *Crawler) { c.urlFrontier = f }
+}
+
+// WithBudget makes a `*crawler.Crawler` stop as soon as `b` is exhausted.
+//
+// NOTE: `b` only accounts for pages and bytes when it is also given to the
+// `*internal.Worker` of the pipeline through `internal.WithBudget`.
+func WithBudget(b *internal.Budget) func(*Crawler) {
+	return func(c *Crawler) { c.budget = b }
+}
+
+// WithCheckpoint makes a `*crawler.Crawler` save a `*checkpoint.Checkpoint` in
+// the `dir` directory `every` period of time and once the crawl is over. It is
+// built from its frontier, the URLs seen by `a` and the site map of `m`.
+//
+// NOTE: `*domain.Target`s still in the pipeline are saved back in the
+// frontier, so pages being crawled when a checkpoint is taken are crawled again
+// on resume instead of being lost. A `*domain.Target` leaves the pipeline when
+// it is discarded by a stage for any reason but cancellation, when it leaves an
+// `internal.Consumer` or when it reaches the end of the pipeline before the
+// crawl is cancelled. Only the `internal.Pipe`s implementing
+// `internal.Observable` are followed, so a `*domain.Target` discarded by
+// another one is crawled again on resume.
+func WithCheckpoint(dir string, every time.Duration, a *internal.Archiver, m *mapper.Mapper) func(*Crawler) {
+	return func(c *Crawler) {
+		c.checkpointDir = dir
+		c.checkpointEvery = every
+		c.archiver = a
+		c.mapper = m
+	}
+}
+
+// WithObserver makes a `*crawler.Crawler` tell `o` about every
+// `internal.Event` occurring in the `internal.Pipe`s implementing
+// `internal.Observable`. It can be given several times.
+func WithObserver(o internal.Observer) func(*Crawler) {
+	return func(c *Crawler) { c.observers = append(c.observers, o) }
+}
+
+// watchBudget cancels the crawl through `cancel` as soon as `c.budget` is
+// exhausted or returns when `ctx` is done.
+func (c *Crawler) watchBudget(ctx context.Context, cancel context.CancelFunc) {
+	select {
+	case <-c.budget.Done():
+		cancel()
+	case <-ctx.Done():
+	}
+}
+
+// Checkpoint returns the current state of the crawl as a
+// `*checkpoint.Checkpoint`.
+//
+// NOTE: URLs of `*domain.Target`s in the frontier or still in the pipeline
+// are removed from the seen URLs and the site map, so that they are crawled
+// again when resuming from the returned checkpoint.
+//
+// NOTE: This function is thread-safe.
+func (c *Crawler) Checkpoint() *checkpoint.Checkpoint {
+	c.mu.Lock()
+	cp := &checkpoint.Checkpoint{}
+	for _, t := range c.handed {
+		cp.Frontier = append(cp.Frontier, t)
+	}
+	sort.Slice(cp.Frontier, func(i, j int) bool {
+		return keyOf(cp.Frontier[i]).less(keyOf(cp.Frontier[j]))
+	})
+	cp.Frontier = append(cp.Frontier, c.urlFrontier.Snapshot()...)
+	cp.Frontier = append(cp.Frontier, c.inbox...)
+	c.mu.Unlock()
+
+	pending := make(map[string]bool, len(cp.Frontier))
+	for _, t := range cp.Frontier {
+		pending[t.BaseURL] = true
+	}
+
+	if c.archiver != nil {
+		for _, url := range c.archiver.Seen() {
+			if !pending[url] {
+				cp.Seen = append(cp.Seen, url)
+			}
+		}
+	}
+	if c.mapper != nil {
+		for _, url := range c.mapper.SiteMap() {
+			if !pending[url] {
+				cp.SiteMap = append(cp.SiteMap, url)
+			}
+		}
+	}
+	return cp
+}
+
+// keep saves `t` in the next checkpoint along with the `*domain.Target`s
+// still in the pipeline, until it is pushed back to `c.urlFrontier`.
+//
+// NOTE: This function is thread-safe.
+func (c *Crawler) keep(t *domain.Target) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.checkpointDir) != 0 {
+		c.handed[keyOf(t)] = t
+	}
+}
+
+// handedKey is a `struct` identifying a `*domain.Target` still in the
+// pipeline, so that a link found to the same URL is not mistaken for it.
+type handedKey struct {
+	url    string
+	parent string
+	depth  int
+}
+
+// keyOf returns the `crawler.handedKey` of `t`.
+func keyOf(t *domain.Target) handedKey {
+	return handedKey{url: t.BaseURL, parent: t.Parent, depth: t.Depth}
+}
+
+// less returns `true` if `k` is sorted before `other`.
+func (k handedKey) less(other handedKey) bool {
+	if k.url != other.url {
+		return k.url < other.url
+	} else if k.parent != other.parent {
+		return k.parent < other.parent
+	}
+	return k.depth < other.depth
+}
+
+// release removes the `*domain.Target` identified by `k` from the ones still
+// in the pipeline.
+//
+// NOTE: This function is thread-safe.
+func (c *Crawler) release(k handedKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.handed, k)
 }
 
-// NewCrawler returns a new `*crawler.Crawler`.
-func NewCrawler() *Crawler {
-	return &Crawler{
-		urlFrontier: make(chan *domain.Target, 10),
+// tracker is an `internal.Observer` keeping the `*domain.Target`s emitted by
+// the `internal.Pipe` it is given to and releasing the ones leaving the
+// pipeline through it. `consumer` is `true` if this `internal.Pipe` implements
+// `internal.Consumer`.
+type tracker struct {
+	c        *Crawler
+	consumer bool
+}
+
+// Observe implements the `internal.Observer` interface.
+//
+// NOTE: This function is thread-safe.
+func (tr *tracker) Observe(e internal.Event) {
+	if e.Kind == internal.EventEmit {
+		tr.c.keep(&domain.Target{BaseURL: e.URL, Parent: e.Parent, Depth: e.Depth})
+		return
+	}
+
+	discarded := e.Kind == internal.EventDiscard && e.Reason != internal.ReasonCancelled
+	if discarded || (e.Kind == internal.EventLeave && tr.consumer) {
+		tr.c.release(handedKey{url: e.URL, parent: e.Parent, depth: e.Depth})
+	}
+}
+
+// FrontierLen returns the number of `*domain.Target`s waiting in the frontier
+// of `c`, including the ones given to `c.Inject` that have not been moved to
+// it yet.
+//
+// NOTE: This function is thread-safe.
+func (c *Crawler) FrontierLen() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.urlFrontier.Len() + len(c.inbox)
+}
+
+// saveCheckpoints saves a `*checkpoint.Checkpoint` in `c.checkpointDir` every
+// `c.checkpointEvery` until `stop` is closed. After that it will close `done`.
+func (c *Crawler) saveCheckpoints(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(c.checkpointEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := checkpoint.Save(c.checkpointDir, c.Checkpoint()); err != nil {
+				log.Printf("Crawler: %v", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// push adds `t` to `c.urlFrontier` and releases it from the next checkpoint
+// at once, so that it is never missing from both.
+//
+// NOTE: This function is thread-safe.
+func (c *Crawler) push(t *domain.Target) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.handed, keyOf(t))
+	c.urlFrontier.Push(t)
+	c.cond.Signal()
+}
+
+// close closes `c.urlFrontier` once the crawl is over.
+//
+// NOTE: This function is thread-safe.
+func (c *Crawler) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	c.urlFrontier.Close()
+	c.cond.Broadcast()
+}
+
+// pipeStart is the function representing the entry of the internal crawling
+// pipeline. It sends every `*domain.Target` popped from `c.urlFrontier` to
+// `out`.
+//
+// NOTE: Nothing is sent while `c` is paused.
+//
+// NOTE: This function will loop until `c.urlFrontier` is closed and empty.
+// After that it will close `out`.
+func (c *Crawler) pipeStart(out chan<- *domain.Target) {
+	defer close(out)
+
+	for {
+		c.mu.Lock()
+		for (c.urlFrontier.Len() == 0 || c.paused) && !c.closed {
+			c.cond.Wait()
+		}
+		if c.urlFrontier.Len() == 0 {
+			c.mu.Unlock()
+			return
+		}
+
+		t, _ := c.urlFrontier.Pop()
+		if len(c.checkpointDir) != 0 {
+			c.handed[keyOf(t)] = t
+		}
+		c.mu.Unlock()
+
+		out <- t
 	}
 }
 
 // pipeEnd is the function representing the edge of the internal crawling
 // pipeline. It cycles new links found during any `crawler.Pipe` to
-// `c.urlFrontier`.
+// `c.urlFrontier`, along with the `*domain.Target`s given to `c.Inject`. Once
+// `ctx` is cancelled, new links are discarded instead and kept for the next
+// checkpoint.
 //
-// NOTE: This function will loop over a channel until `in` is closed.
+// NOTE: This function will loop over a channel until `in` is closed. After
+// that it will close `done`.
 //
 // NOTE: All `crawler.Pipe`s have to `wg.Done()` each time they discard a
 // `*domain.Target` from the pipeline.
-func (c *Crawler) pipeEnd(in <-chan *domain.Target) {
+func (c *Crawler) pipeEnd(ctx context.Context, wg *sync.WaitGroup, in <-chan *domain.Target, done chan<- struct{}) {
+	defer close(done)
+
 	for t := range in {
-		c.urlFrontier <- t
+		if ctx.Err() != nil {
+			c.keep(t)
+			wg.Done()
+		} else {
+			c.drainInbox(wg, false)
+			c.push(t)
+		}
+	}
+}
+
+// wait returns once every `*domain.Target` has been processed. When
+// `c.terminator` is set, it waits for it to be done instead, crawling the
+// `*domain.Target`s given to `c.Inject` in the meantime.
+func (c *Crawler) wait(ctx context.Context, wg *sync.WaitGroup) {
+	for {
+		wg.Wait()
+		if ctx.Err() != nil {
+			return
+		} else if c.drainInbox(wg, true) {
+			continue
+		} else if c.terminator == nil {
+			return
+		}
+
+		select {
+		case <-c.wake:
+		case <-c.terminator.Done():
+			c.mu.Lock()
+			c.closed = true
+			c.mu.Unlock()
+			return
+		case <-ctx.Done():
+			return
+		}
 	}
 }
 
 // Run ties all the `crawler.Pipe`s together and initiates the web crawling
-// mechanism.
+// mechanism from `seeds`. It returns once every `*domain.Target` has been
+// processed and every `internal.Pipe` has returned.
+//
+// When a `crawler.Terminator` is configured, `Run` waits for `*domain.Target`s
+// given to `c.Inject` instead of returning once every `*domain.Target` has
+// been processed, until the `crawler.Terminator` is done.
+//
+// When `ctx` is cancelled, `*domain.Target`s still in the pipeline are
+// drained and `ctx.Err()` is returned. They are drained as well when
+// `c.Stop` is called, but no `error` is returned. The same goes when `c.budget` is
+// exhausted, in which case the `error` that exhausted it is returned.
+//
+// When a checkpoint directory is configured, a last checkpoint is saved before
+// returning so that an interrupted crawl can be resumed.
+//
+// Errors reported by `internal.Pipe`s implementing `internal.ErrorReporter`
+// are not returned but collected in `c.ErrorSummary`. Likewise, events
+// occurring in `internal.Pipe`s implementing `internal.Observable` are sent to
+// the `internal.Observer`s given through `crawler.WithObserver`.
 //
 // NOTE: It is highly recommended to insert a `internal.Pipe` keeping track of
 // already visited web pages in order to avoid looping indefinitely on the same
 // links. The `crawler.Archiver` can be used for this goal.
-func (c *Crawler) Run(t *domain.Target, pipeline ...internal.Pipe) error {
+func (c *Crawler) Run(ctx context.Context, seeds []*domain.Target, pipeline ...internal.Pipe) error {
 	wg := sync.WaitGroup{}
+	done := make(chan struct{})
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	in := c.urlFrontier
+	c.mu.Lock()
+	c.cancel, c.stopped, c.runDone = cancel, false, runCtx.Done()
+	c.mu.Unlock()
+	go c.resumeOnDone(runCtx)
+
+	wg.Add(c.urlFrontier.Len() + len(seeds))
+	for _, t := range seeds {
+		c.push(t)
+	}
+
+	if c.budget != nil {
+		c.budget.Start()
+		go c.watchBudget(runCtx, cancel)
+	}
+
+	stopCheckpoints := make(chan struct{})
+	checkpointsDone := make(chan struct{})
+	if len(c.checkpointDir) != 0 {
+		go c.saveCheckpoints(stopCheckpoints, checkpointsDone)
+	} else {
+		close(checkpointsDone)
+	}
+
+	start := make(chan *domain.Target)
+	go c.pipeStart(start)
+
+	var in <-chan *domain.Target = start
 	for _, pipe := range pipeline {
+		if r, ok := pipe.(internal.ErrorReporter); ok {
+			r.SetErrorSink(c)
+		}
+		if o, ok := pipe.(internal.Observable); ok {
+			obs := append([]internal.Observer{}, c.observers...)
+			if len(c.checkpointDir) != 0 {
+				_, consumer := pipe.(internal.Consumer)
+				obs = append(obs, &tracker{c: c, consumer: consumer})
+			}
+			o.SetObservers(obs...)
+		}
 		out := make(chan *domain.Target)
-		go pipe.Pipe(&wg, in, out)
+		go pipe.Pipe(runCtx, &wg, in, out)
 		in = out
 	}
-	go c.pipeEnd(in)
+	go c.pipeEnd(runCtx, &wg, in, done)
+
+	c.wait(runCtx, &wg)
+	c.close()
+	<-done
+	close(stopCheckpoints)
+	<-checkpointsDone
+
+	err := ctx.Err()
+	if c.budget != nil {
+		c.budget.Stop()
+		if c.budget.Err() != nil {
+			err = c.budget.Err()
+		}
+	}
 
-	wg.Add(1)
-	c.urlFrontier <- t
-	wg.Wait()
-	close(c.urlFrontier)
+	c.mu.Lock()
+	c.cancel = nil
+	stopped := c.stopped
+	c.mu.Unlock()
 
-	return nil
+	if len(c.checkpointDir) != 0 {
+		if err == nil && !stopped {
+			c.mu.Lock()
+			c.handed = make(map[handedKey]*domain.Target)
+			c.mu.Unlock()
+		}
+		if cpErr := checkpoint.Save(c.checkpointDir, c.Checkpoint()); cpErr != nil && err == nil {
+			err = fmt.Errorf("Run: %v", cpErr)
+		}
+	}
+	return err
 }
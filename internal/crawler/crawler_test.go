@@ -1,16 +1,24 @@
 package crawler
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/timtosi/mcrawler/crawltest"
 	"github.com/timtosi/mcrawler/internal"
+	"github.com/timtosi/mcrawler/internal/checkpoint"
 	"github.com/timtosi/mcrawler/internal/domain"
 	"github.com/timtosi/mcrawler/internal/extractor"
 	"github.com/timtosi/mcrawler/internal/mapper"
@@ -91,12 +99,13 @@ func TestCrawler_Run(t *testing.T) {
 	}
 
 	if err := NewCrawler().Run(
-		tgt,
+		context.Background(),
+		[]*domain.Target{tgt},
 		internal.NewArchiver(),
-		m,
 		f,
 		internal.NewWorker(),
-		extractor.NewExtractor(extractor.GetImg, extractor.GetLinkNoFollow),
+		m,
+		extractor.NewExtractor([]extractor.CheckFunc{extractor.GetImg, extractor.GetLinkNoFollow}),
 	); err != nil {
 		log.Fatal(err)
 	}
@@ -107,14 +116,619 @@ func TestCrawler_Run(t *testing.T) {
 			"http://localhost:8080/home",
 			"http://localhost:8080/team",
 			"http://localhost:8080/about",
-			"http://localhost:8080/notfound",
-			"http://localhost:8080/img1.jpg",
-			"http://localhost:8080/img2.png",
-			"http://localhost:8080/img3.png",
-			"http://external1.com",
-			"http://external2.com",
-			"ftp://www.run-test.com",
 		},
 		m.SiteMap(),
 	)
 }
+
+// mockObserver is a `struct` only used for test purposes. It implements the
+// `internal.Observer` interface by counting events per stage, kind and reason.
+type mockObserver struct {
+	counts map[string]int
+	mu     sync.Mutex
+}
+
+// Observe implements the `internal.Observer` interface.
+func (mo *mockObserver) Observe(e internal.Event) {
+	mo.mu.Lock()
+	defer mo.mu.Unlock()
+
+	mo.counts[fmt.Sprintf("%s/%s/%s", e.Stage, e.Kind, e.Reason)]++
+}
+
+func TestCrawler_Run_crawltest(t *testing.T) {
+	s := crawltest.NewServer(crawltest.Site{
+		Pages:     40,
+		FanOut:    3,
+		Broken:    3,
+		Redirects: 2,
+		NoFollow:  2,
+		OffHost:   2,
+		Slow:      2,
+		SlowDelay: 20 * time.Millisecond,
+	})
+	defer s.Close()
+
+	m := mapper.NewMapper()
+	f, err := internal.NewFollower(s.Root())
+	if err != nil {
+		log.Fatalf("TestCrawler_Run_crawltest: %v", err)
+	}
+
+	a := internal.NewArchiver()
+	assert.Nil(t, NewCrawler().Run(
+		context.Background(),
+		[]*domain.Target{domain.NewTarget(s.Root())},
+		a,
+		f,
+		internal.NewWorker(internal.WithConcurrency(8), internal.WithRedirectCheckers(f, a)),
+		m,
+		extractor.NewExtractor([]extractor.CheckFunc{extractor.GetLinkNoFollow}),
+	))
+
+	assert.ElementsMatch(t, s.SiteMap(), m.SiteMap())
+	for _, link := range s.Targets(crawltest.LinkNoFollow) {
+		assert.Equal(t, 0, s.Hits(link), link)
+	}
+	for _, link := range s.Targets(crawltest.LinkBroken) {
+		assert.Equal(t, 1, s.Hits(link), link)
+	}
+}
+
+func TestCrawler_Run_seeds(t *testing.T) {
+	newSite := func(page string) *httptest.Server {
+		return httptest.NewServer(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/" {
+					w.Write([]byte(`<a href="/` + page + `">page</a><a href="http://external.com">ext</a>`))
+				}
+			}),
+		)
+	}
+	siteA, siteB := newSite("a"), newSite("b")
+	defer siteA.Close()
+	defer siteB.Close()
+
+	m := mapper.NewMapper()
+	f, err := internal.NewFollower(siteA.URL, siteB.URL)
+	if err != nil {
+		log.Fatalf("TestCrawler_Run_seeds: %v", err)
+	}
+
+	assert.Nil(t, NewCrawler().Run(
+		context.Background(),
+		[]*domain.Target{domain.NewTarget(siteA.URL + "/"), domain.NewTarget(siteB.URL + "/")},
+		internal.NewArchiver(),
+		f,
+		internal.NewWorker(),
+		m,
+		extractor.NewExtractor([]extractor.CheckFunc{extractor.GetLinkNoFollow}),
+	))
+	assert.ElementsMatch(
+		t,
+		[]string{siteA.URL + "/", siteA.URL + "/a", siteB.URL + "/", siteB.URL + "/b"},
+		m.SiteMap(),
+	)
+}
+
+func TestCrawler_WithObserver(t *testing.T) {
+	fs, err := mockServer()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer fs.Close()
+
+	tgt := domain.NewTarget("http://localhost:8080/home")
+	f, err := internal.NewFollower(tgt.BaseURL)
+	if err != nil {
+		log.Fatalf("TestCrawler_WithObserver: %v", err)
+	}
+
+	mo := &mockObserver{counts: make(map[string]int)}
+	if err := NewCrawler(WithObserver(mo)).Run(
+		context.Background(),
+		[]*domain.Target{tgt},
+		internal.NewArchiver(),
+		f,
+		internal.NewWorker(),
+		mapper.NewMapper(),
+		extractor.NewExtractor([]extractor.CheckFunc{extractor.GetImg, extractor.GetLinkNoFollow}),
+	); err != nil {
+		log.Fatal(err)
+	}
+
+	for _, stage := range []string{"archiver", "mapper", "follower", "worker", "extractor"} {
+		enter := mo.counts[stage+"/enter/"]
+		left := mo.counts[stage+"/leave/"]
+		for key, count := range mo.counts {
+			if strings.HasPrefix(key, stage+"/discard/") {
+				left += count
+			}
+		}
+		assert.Equal(t, enter, left, stage)
+	}
+	assert.Equal(t, mo.counts["archiver/enter/"], mo.counts["extractor/emit/"]+1)
+	assert.Equal(t, 10, mo.counts["archiver/leave/"])
+	assert.Equal(t, 3, mo.counts["follower/discard/off-host"])
+	assert.Equal(t, 3, mo.counts["worker/leave/"])
+	assert.Equal(t, 4, mo.counts["worker/discard/bad-status"])
+	assert.Equal(t, 3, mo.counts["extractor/leave/"])
+}
+
+func TestCrawler_WithFrontier(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mockFrontier Frontier
+	}{
+		{"fifo", NewFIFOFrontier()},
+		{"lifo", NewLIFOFrontier()},
+		{"priority", NewPriorityFrontier(DeepFirst)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, err := mockServer()
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer fs.Close()
+
+			tgt := domain.NewTarget("http://localhost:8080/home")
+			m := mapper.NewMapper()
+			f, err := internal.NewFollower(tgt.BaseURL)
+			if err != nil {
+				log.Fatalf("%s: %v", tc.name, err)
+			}
+
+			assert.Nil(t, NewCrawler(WithFrontier(tc.mockFrontier)).Run(
+				context.Background(),
+				[]*domain.Target{tgt},
+				internal.NewArchiver(),
+				f,
+				internal.NewWorker(),
+				m,
+				extractor.NewExtractor([]extractor.CheckFunc{extractor.GetImg, extractor.GetLinkNoFollow}),
+			))
+			assert.ElementsMatch(
+				t,
+				[]string{
+					"http://localhost:8080/home",
+					"http://localhost:8080/team",
+					"http://localhost:8080/about",
+				},
+				m.SiteMap(),
+			)
+			assert.Equal(t, 0, tc.mockFrontier.Len())
+		})
+	}
+}
+
+func TestCrawler_WithCheckpoint(t *testing.T) {
+	testCases := []struct {
+		name            string
+		mockCheckpoint  *checkpoint.Checkpoint
+		expectedSiteMap []string
+	}{
+		{
+			"fresh",
+			&checkpoint.Checkpoint{},
+			[]string{
+				"http://localhost:8080/home",
+				"http://localhost:8080/team",
+				"http://localhost:8080/about",
+			},
+		},
+		{
+			"resumed",
+			&checkpoint.Checkpoint{
+				Frontier: []*domain.Target{
+					domain.NewChildTarget("http://localhost:8080/team", domain.NewTarget("http://localhost:8080/home")),
+				},
+				Seen:    []string{"http://localhost:8080/home"},
+				SiteMap: []string{"http://localhost:8080/home"},
+			},
+			[]string{
+				"http://localhost:8080/home",
+				"http://localhost:8080/team",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, err := mockServer()
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer fs.Close()
+
+			dir, err := ioutil.TempDir("", "crawler")
+			if err != nil {
+				log.Fatalf("%s: %v", tc.name, err)
+			}
+			defer os.RemoveAll(dir)
+
+			tgt := domain.NewTarget("http://localhost:8080/home")
+			a := internal.NewArchiver()
+			a.Restore(tc.mockCheckpoint.Seen)
+			m := mapper.NewMapper()
+			for _, link := range tc.mockCheckpoint.SiteMap {
+				m.Add(link)
+			}
+			fr := NewFIFOFrontier()
+			for _, pending := range tc.mockCheckpoint.Frontier {
+				fr.Push(pending)
+			}
+			f, err := internal.NewFollower(tgt.BaseURL)
+			if err != nil {
+				log.Fatalf("%s: %v", tc.name, err)
+			}
+
+			assert.Nil(t, NewCrawler(WithFrontier(fr), WithCheckpoint(dir, time.Millisecond, a, m)).Run(
+				context.Background(),
+				[]*domain.Target{tgt},
+				a,
+				f,
+				internal.NewWorker(),
+				m,
+				extractor.NewExtractor([]extractor.CheckFunc{extractor.GetImg, extractor.GetLinkNoFollow}),
+			))
+			assert.ElementsMatch(t, tc.expectedSiteMap, m.SiteMap())
+
+			cp, err := checkpoint.Load(dir)
+			assert.Nil(t, err)
+			assert.Empty(t, cp.Frontier)
+			assert.ElementsMatch(t, tc.expectedSiteMap, cp.SiteMap)
+			assert.ElementsMatch(t, a.Seen(), cp.Seen)
+		})
+	}
+}
+
+func TestCrawler_WithCheckpoint_drain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crawler")
+	if err != nil {
+		t.Fatalf("TestCrawler_WithCheckpoint_drain: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	hold := internal.Map(func(tgt *domain.Target) (*domain.Target, error) {
+		entered <- struct{}{}
+		<-release
+		return tgt, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	held := domain.NewTarget("http://a.com/held")
+	c := NewCrawler(WithCheckpoint(dir, time.Millisecond, internal.NewArchiver(), mapper.NewMapper()))
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.Run(
+			ctx,
+			[]*domain.Target{domain.NewTarget("http://a.com/done"), held},
+			hold,
+			extractor.NewExtractor(nil),
+		)
+	}()
+
+	<-entered
+	release <- struct{}{}
+	<-entered
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	release <- struct{}{}
+	assert.Equal(t, context.Canceled, <-errs)
+
+	cp, err := checkpoint.Load(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, []*domain.Target{held}, cp.Frontier)
+}
+
+func TestCrawler_Checkpoint(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mockSeen []string
+		mockTodo []string
+		mockDone []string
+		expected *checkpoint.Checkpoint
+	}{
+		{
+			"empty",
+			nil,
+			nil,
+			nil,
+			&checkpoint.Checkpoint{},
+		},
+		{
+			"regular",
+			[]string{"http://a.com", "http://a.com/handed", "http://a.com/todo"},
+			[]string{"http://a.com/todo"},
+			[]string{"http://a.com/handed"},
+			&checkpoint.Checkpoint{
+				Frontier: []*domain.Target{
+					domain.NewTarget("http://a.com/handed"),
+					domain.NewTarget("http://a.com/todo"),
+				},
+				Seen:    []string{"http://a.com"},
+				SiteMap: []string{"http://a.com"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := internal.NewArchiver()
+			a.Restore(tc.mockSeen)
+			m := mapper.NewMapper()
+			for _, link := range tc.mockSeen {
+				m.Add(link)
+			}
+
+			c := NewCrawler(WithCheckpoint("unused", time.Hour, a, m))
+			for _, link := range tc.mockDone {
+				tgt := domain.NewTarget(link)
+				c.handed[keyOf(tgt)] = tgt
+			}
+			for _, link := range tc.mockTodo {
+				c.push(domain.NewTarget(link))
+			}
+
+			assert.Equal(t, tc.expected, c.Checkpoint())
+			assert.Len(t, c.handed, len(tc.mockDone))
+		})
+	}
+}
+
+func TestCrawler_Run_budget(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mockBudget  *internal.Budget
+		expectedErr error
+	}{
+		{"noLimit", internal.NewBudget(), nil},
+		{"maxPages", internal.NewBudget(internal.WithMaxPages(2)), internal.ErrMaxPages},
+		{"maxBytes", internal.NewBudget(internal.WithMaxBytes(100)), internal.ErrMaxBytes},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, err := mockServer()
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer fs.Close()
+
+			tgt := domain.NewTarget("http://localhost:8080/home")
+			f, err := internal.NewFollower(tgt.BaseURL)
+			if err != nil {
+				log.Fatalf("%s: %v", tc.name, err)
+			}
+
+			assert.Equal(t, tc.expectedErr, NewCrawler(WithBudget(tc.mockBudget)).Run(
+				context.Background(),
+				[]*domain.Target{tgt},
+				internal.NewArchiver(),
+				f,
+				internal.NewWorker(internal.WithBudget(tc.mockBudget)),
+				extractor.NewExtractor([]extractor.CheckFunc{extractor.GetImg, extractor.GetLinkNoFollow}),
+			))
+		})
+	}
+}
+
+func TestCrawler_Run_cancel(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mockTimeout time.Duration
+	}{
+		{"alreadyCancelled", 0},
+		{"cancelledDuringFetch", 100 * time.Millisecond},
+	}
+
+	slowServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}),
+	)
+	defer slowServer.Close()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			goroutines := runtime.NumGoroutine()
+			ctx, cancel := context.WithTimeout(context.Background(), tc.mockTimeout)
+			defer cancel()
+
+			tgt := domain.NewTarget(slowServer.URL)
+			m := mapper.NewMapper()
+			f, err := internal.NewFollower(tgt.BaseURL)
+			if err != nil {
+				log.Fatalf("%s: %v", tc.name, err)
+			}
+
+			start := time.Now()
+			err = NewCrawler().Run(
+				ctx,
+				[]*domain.Target{tgt},
+				internal.NewArchiver(),
+				f,
+				internal.NewWorker(),
+				m,
+				extractor.NewExtractor([]extractor.CheckFunc{extractor.GetImg, extractor.GetLinkNoFollow}),
+			)
+			assert.Equal(t, ctx.Err(), err)
+			assert.True(t, time.Since(start) < 2*time.Second)
+
+			time.Sleep(100 * time.Millisecond)
+			assert.True(t, runtime.NumGoroutine() <= goroutines)
+		})
+	}
+}
+
+func TestCrawler_ErrorSummary(t *testing.T) {
+	brokenServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/":
+				w.Write([]byte(`<html><body><a href="/broken">broken</a></body></html>`))
+			case "/broken":
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					log.Fatalf("Crawler ErrorSummary: %v", err)
+				}
+				conn.Close()
+			}
+		}),
+	)
+	defer brokenServer.Close()
+
+	tgt := domain.NewTarget(brokenServer.URL + "/")
+	f, err := internal.NewFollower(tgt.BaseURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	c := NewCrawler()
+	assert.Nil(t, c.Run(
+		context.Background(),
+		[]*domain.Target{tgt},
+		internal.NewArchiver(),
+		f,
+		internal.NewWorker(),
+		extractor.NewExtractor([]extractor.CheckFunc{extractor.GetLinkNoFollow}),
+	))
+
+	s := c.ErrorSummary()
+	assert.Len(t, s.Errors, 1)
+	assert.Equal(t, brokenServer.URL+"/broken", s.Errors[0].URL)
+	assert.Equal(t, "worker", s.Errors[0].Stage)
+	assert.Equal(t, map[string]int{"worker": 1}, s.ByStage)
+	assert.Equal(t, "1 page errors (worker=1)", s.String())
+}
+
+func TestErrorSummary_String(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mockErrs    []*internal.CrawlError
+		expectedStr string
+	}{
+		{"noError", nil, "0 page errors"},
+		{
+			"severalStages",
+			[]*internal.CrawlError{{Stage: "worker"}, {Stage: "follower"}, {Stage: "worker"}},
+			"3 page errors (follower=1, worker=2)",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedStr, newErrorSummary(tc.mockErrs).String())
+		})
+	}
+}
+
+func TestErrorSummary_maxErrors(t *testing.T) {
+	c := NewCrawler()
+	for i := 0; i < maxErrors+1; i++ {
+		c.Report(&internal.CrawlError{Stage: "worker"})
+	}
+
+	s := c.ErrorSummary()
+	assert.Len(t, s.Errors, maxErrors)
+	assert.Equal(t, maxErrors+1, s.Total)
+	assert.Equal(t, map[string]int{"worker": maxErrors + 1}, s.ByStage)
+}
+
+// discard is an `internal.Pipe` only used for test purposes. It discards
+// every `*domain.Target` so that a crawl ends once its seeds are processed.
+var discard = internal.Filter(func(*domain.Target) bool { return false })
+
+// mockFrontier is a `struct` only used for test purposes. It wraps a
+// `crawler.Frontier` and sends every `*domain.Target` pushed to it to
+// `pushed`, so that tests know when `Run` has started.
+type mockFrontier struct {
+	Frontier
+	pushed chan *domain.Target
+}
+
+// newMockFrontier returns a new `*crawler.mockFrontier` wrapping a FIFO
+// `crawler.Frontier`.
+func newMockFrontier() *mockFrontier {
+	return &mockFrontier{Frontier: NewFIFOFrontier(), pushed: make(chan *domain.Target, 8)}
+}
+
+// Push implements the `crawler.Frontier` interface.
+func (mf *mockFrontier) Push(t *domain.Target) {
+	mf.Frontier.Push(t)
+	mf.pushed <- t
+}
+
+func TestCrawler_Pause(t *testing.T) {
+	m := mapper.NewMapper()
+	mf := newMockFrontier()
+	c := NewCrawler(WithFrontier(mf))
+	c.Pause()
+	assert.True(t, c.Paused())
+
+	errs := make(chan error)
+	go func() {
+		errs <- c.Run(context.Background(), []*domain.Target{domain.NewTarget("http://a.com")}, m, discard)
+	}()
+
+	<-mf.pushed
+	assert.Empty(t, m.SiteMap())
+	assert.Equal(t, 1, c.FrontierLen())
+
+	c.Resume()
+	assert.False(t, c.Paused())
+	assert.Nil(t, <-errs)
+	assert.Equal(t, []string{"http://a.com"}, m.SiteMap())
+}
+
+func TestCrawler_Stop(t *testing.T) {
+	m := mapper.NewMapper()
+	mf := newMockFrontier()
+	c := NewCrawler(WithFrontier(mf))
+	assert.NotPanics(t, c.Stop)
+
+	c.Pause()
+	errs := make(chan error)
+	go func() {
+		errs <- c.Run(context.Background(), []*domain.Target{domain.NewTarget("http://a.com")}, m, discard)
+	}()
+
+	<-mf.pushed
+	c.Stop()
+	select {
+	case err := <-errs:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("TestCrawler_Stop: Run did not return")
+	}
+	assert.Empty(t, m.SiteMap())
+}
+
+func TestCrawler_Seed(t *testing.T) {
+	m := mapper.NewMapper()
+	mf := newMockFrontier()
+	c := NewCrawler(WithFrontier(mf))
+	c.Pause()
+
+	errs := make(chan error)
+	go func() {
+		errs <- c.Run(context.Background(), []*domain.Target{domain.NewTarget("http://a.com")}, m, discard)
+	}()
+
+	<-mf.pushed
+	assert.Nil(t, c.Seed(domain.NewTarget("http://b.com")))
+	assert.Equal(t, 2, c.FrontierLen())
+
+	c.Resume()
+	assert.Nil(t, <-errs)
+	assert.ElementsMatch(t, []string{"http://a.com", "http://b.com"}, m.SiteMap())
+	assert.Equal(t, ErrFinished, c.Seed(domain.NewTarget("http://c.com")))
+	assert.Equal(t, 0, c.FrontierLen())
+}
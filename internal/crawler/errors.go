@@ -0,0 +1,97 @@
+package crawler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/timtosi/mcrawler/internal"
+)
+
+// maxErrors is the maximum number of `*internal.CrawlError`s kept in an
+// `*crawler.ErrorSummary`, so that a large crawl does not hold every one of
+// them in memory.
+const maxErrors = 1000
+
+// ErrorSummary is a `struct` aggregating the `*internal.CrawlError`s reported
+// by the `internal.Pipe`s of a crawl. They describe pages that could not be
+// crawled rather than failures of the crawler itself, which are returned by
+// `Crawler.Run`.
+//
+// NOTE: Only the first `crawler.maxErrors` errors are kept in `Errors`, while
+// `Total` and `ByStage` account for every one of them.
+type ErrorSummary struct {
+	Errors  []*internal.CrawlError
+	Total   int
+	ByStage map[string]int
+}
+
+// newErrorSummary returns a new `*crawler.ErrorSummary` built from `errs`.
+func newErrorSummary(errs []*internal.CrawlError) *ErrorSummary {
+	s := &ErrorSummary{ByStage: make(map[string]int)}
+
+	for _, e := range errs {
+		s.add(e)
+	}
+	return s
+}
+
+// add accounts for `e` in `s`.
+func (s *ErrorSummary) add(e *internal.CrawlError) {
+	if len(s.Errors) < maxErrors {
+		s.Errors = append(s.Errors, e)
+	}
+	s.Total++
+	s.ByStage[e.Stage]++
+}
+
+// copy returns a copy of `s`.
+func (s *ErrorSummary) copy() *ErrorSummary {
+	cp := &ErrorSummary{
+		Errors:  append([]*internal.CrawlError(nil), s.Errors...),
+		Total:   s.Total,
+		ByStage: make(map[string]int, len(s.ByStage)),
+	}
+
+	for stage, count := range s.ByStage {
+		cp.ByStage[stage] = count
+	}
+	return cp
+}
+
+// String returns a one line description of `s`.
+func (s *ErrorSummary) String() string {
+	stages := make([]string, 0, len(s.ByStage))
+	for stage, count := range s.ByStage {
+		stages = append(stages, fmt.Sprintf("%s=%d", stage, count))
+	}
+	sort.Strings(stages)
+
+	if len(stages) == 0 {
+		return "0 page errors"
+	}
+	return fmt.Sprintf("%d page errors (%s)", s.Total, strings.Join(stages, ", "))
+}
+
+// Report implements the `internal.ErrorSink` interface by adding `e` to
+// `c.errs`.
+//
+// NOTE: This function is thread-safe.
+func (c *Crawler) Report(e *internal.CrawlError) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.errs.add(e)
+}
+
+// ErrorSummary returns a `*crawler.ErrorSummary` of the
+// `*internal.CrawlError`s reported so far by the `internal.Pipe`s given to
+// `c.Run`.
+//
+// NOTE: This function is thread-safe.
+func (c *Crawler) ErrorSummary() *ErrorSummary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.errs.copy()
+}
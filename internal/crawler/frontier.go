@@ -0,0 +1,258 @@
+package crawler
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+// Frontier is an `interface` holding the `*domain.Target`s waiting to be
+// sent in the crawling pipeline. Its implementation decides the order in which
+// web pages are crawled.
+type Frontier interface {
+	// Push adds a `*domain.Target` to the `Frontier`.
+	Push(*domain.Target)
+	// Pop removes and returns the next `*domain.Target` to crawl. It blocks
+	// until one is available and returns `false` once the `Frontier` is
+	// closed and empty.
+	Pop() (*domain.Target, bool)
+	// Len returns the number of `*domain.Target` in the `Frontier`.
+	Len() int
+	// Snapshot returns a copy of the `*domain.Target`s in the `Frontier`, in
+	// the order they have been pushed.
+	Snapshot() []*domain.Target
+	// Close unblocks any pending `Pop` once the `Frontier` is empty.
+	Close()
+}
+
+// store is an `interface` implemented by the containers used by `*queue`.
+type store interface {
+	push(*domain.Target)
+	pop() *domain.Target
+	len() int
+	snapshot() []*domain.Target
+}
+
+// queue is a `struct` turning a `store` into a thread-safe `Frontier`.
+type queue struct {
+	s      store
+	closed bool
+	mu     *sync.Mutex
+	cond   *sync.Cond
+}
+
+// newQueue returns a new `*crawler.queue` wrapping `s`.
+func newQueue(s store) *queue {
+	mu := &sync.Mutex{}
+	return &queue{s: s, mu: mu, cond: sync.NewCond(mu)}
+}
+
+// Push adds `t` to `q`.
+//
+// NOTE: This function is thread-safe.
+func (q *queue) Push(t *domain.Target) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.s.push(t)
+	q.cond.Signal()
+}
+
+// Pop removes and returns the next `*domain.Target` of `q`. It blocks until
+// one is available and returns `false` once `q` is closed and empty.
+//
+// NOTE: This function is thread-safe.
+func (q *queue) Pop() (*domain.Target, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.s.len() == 0 {
+		if q.closed {
+			return nil, false
+		}
+		q.cond.Wait()
+	}
+	return q.s.pop(), true
+}
+
+// Len returns the number of `*domain.Target` in `q`.
+//
+// NOTE: This function is thread-safe.
+func (q *queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.s.len()
+}
+
+// Snapshot returns a copy of the `*domain.Target`s in `q`, in the order they
+// have been pushed.
+//
+// NOTE: This function is thread-safe.
+func (q *queue) Snapshot() []*domain.Target {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.s.snapshot()
+}
+
+// Close unblocks any pending `q.Pop` once `q` is empty.
+//
+// NOTE: This function is thread-safe.
+func (q *queue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// -----------------------------------------------------------------------------
+
+// fifo is a first in, first out `store`.
+type fifo struct {
+	items []*domain.Target
+}
+
+func (f *fifo) push(t *domain.Target) { f.items = append(f.items, t) }
+func (f *fifo) len() int              { return len(f.items) }
+
+func (f *fifo) snapshot() []*domain.Target {
+	return append([]*domain.Target(nil), f.items...)
+}
+
+func (f *fifo) pop() *domain.Target {
+	t := f.items[0]
+	f.items[0] = nil
+	f.items = f.items[1:]
+	return t
+}
+
+// FIFOFrontier is a `Frontier` crawling web pages in the order they are found,
+// leading to a breadth-first crawl.
+type FIFOFrontier struct {
+	*queue
+}
+
+// NewFIFOFrontier returns a new `*crawler.FIFOFrontier`.
+func NewFIFOFrontier() *FIFOFrontier {
+	return &FIFOFrontier{queue: newQueue(&fifo{})}
+}
+
+// -----------------------------------------------------------------------------
+
+// lifo is a last in, first out `store`.
+type lifo struct {
+	items []*domain.Target
+}
+
+func (l *lifo) push(t *domain.Target) { l.items = append(l.items, t) }
+func (l *lifo) len() int              { return len(l.items) }
+
+func (l *lifo) snapshot() []*domain.Target {
+	return append([]*domain.Target(nil), l.items...)
+}
+
+func (l *lifo) pop() *domain.Target {
+	t := l.items[len(l.items)-1]
+	l.items[len(l.items)-1] = nil
+	l.items = l.items[:len(l.items)-1]
+	return t
+}
+
+// LIFOFrontier is a `Frontier` crawling the most recently found web pages
+// first, leading to a depth-first crawl.
+type LIFOFrontier struct {
+	*queue
+}
+
+// NewLIFOFrontier returns a new `*crawler.LIFOFrontier`.
+func NewLIFOFrontier() *LIFOFrontier {
+	return &LIFOFrontier{queue: newQueue(&lifo{})}
+}
+
+// -----------------------------------------------------------------------------
+
+// LessFunc is a named type representing a function reporting whether `a` must
+// be crawled before `b`.
+type LessFunc func(a, b *domain.Target) bool
+
+// ShallowFirst is a `crawler.LessFunc` crawling web pages closer to the seed
+// first.
+func ShallowFirst(a, b *domain.Target) bool {
+	return a.Depth < b.Depth
+}
+
+// DeepFirst is a `crawler.LessFunc` crawling web pages further from the seed
+// first.
+func DeepFirst(a, b *domain.Target) bool {
+	return a.Depth > b.Depth
+}
+
+// prioritized is a `*domain.Target` along with its insertion rank, used to
+// break ties between equal elements.
+type prioritized struct {
+	t    *domain.Target
+	rank uint64
+}
+
+// priority is a `store` implementing `heap.Interface`.
+type priority struct {
+	items []prioritized
+	less  LessFunc
+	rank  uint64
+}
+
+func (p *priority) Len() int      { return len(p.items) }
+func (p *priority) Swap(i, j int) { p.items[i], p.items[j] = p.items[j], p.items[i] }
+func (p *priority) Push(x interface{}) {
+	p.items = append(p.items, x.(prioritized))
+}
+
+func (p *priority) Less(i, j int) bool {
+	if p.less(p.items[i].t, p.items[j].t) {
+		return true
+	} else if p.less(p.items[j].t, p.items[i].t) {
+		return false
+	}
+	return p.items[i].rank < p.items[j].rank
+}
+
+func (p *priority) Pop() interface{} {
+	x := p.items[len(p.items)-1]
+	p.items = p.items[:len(p.items)-1]
+	return x
+}
+
+func (p *priority) push(t *domain.Target) {
+	p.rank++
+	heap.Push(p, prioritized{t: t, rank: p.rank})
+}
+func (p *priority) pop() *domain.Target { return heap.Pop(p).(prioritized).t }
+func (p *priority) len() int            { return len(p.items) }
+
+func (p *priority) snapshot() []*domain.Target {
+	items := append([]prioritized(nil), p.items...)
+	sort.Slice(items, func(i, j int) bool { return items[i].rank < items[j].rank })
+
+	tgts := make([]*domain.Target, 0, len(items))
+	for _, x := range items {
+		tgts = append(tgts, x.t)
+	}
+	return tgts
+}
+
+// PriorityFrontier is a `Frontier` crawling web pages in the order defined by
+// a `crawler.LessFunc`. Equal web pages are crawled in the order they are
+// found.
+type PriorityFrontier struct {
+	*queue
+}
+
+// NewPriorityFrontier returns a new `*crawler.PriorityFrontier` ordered by
+// `less`.
+func NewPriorityFrontier(less LessFunc) *PriorityFrontier {
+	return &PriorityFrontier{queue: newQueue(&priority{less: less})}
+}
@@ -0,0 +1,123 @@
+package crawler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+// mockTargets is a helper function only used for test purposes. It returns a
+// `[]*domain.Target` built from `depths`, where each `*domain.Target` has its
+// index as `BaseURL`.
+func mockTargets(depths ...int) []*domain.Target {
+	tgts := make([]*domain.Target, 0, len(depths))
+	for i, d := range depths {
+		tgts = append(tgts, &domain.Target{BaseURL: string(rune('a' + i)), Depth: d})
+	}
+	return tgts
+}
+
+// -----------------------------------------------------------------------------
+
+func TestFrontier_Pop(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mockFrontier Frontier
+		mockTargets  []*domain.Target
+		expectedURLs []string
+	}{
+		{"fifo_empty", NewFIFOFrontier(), nil, []string{}},
+		{"fifo_regular", NewFIFOFrontier(), mockTargets(0, 1, 1, 2), []string{"a", "b", "c", "d"}},
+		{"lifo_empty", NewLIFOFrontier(), nil, []string{}},
+		{"lifo_regular", NewLIFOFrontier(), mockTargets(0, 1, 1, 2), []string{"d", "c", "b", "a"}},
+		{
+			"priority_shallowFirst",
+			NewPriorityFrontier(ShallowFirst),
+			mockTargets(2, 1, 0, 1, 2),
+			[]string{"c", "b", "d", "a", "e"},
+		},
+		{
+			"priority_deepFirst",
+			NewPriorityFrontier(DeepFirst),
+			mockTargets(2, 1, 0, 1, 2),
+			[]string{"a", "e", "b", "d", "c"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := make([]string, 0)
+			for _, tgt := range tc.mockTargets {
+				tc.mockFrontier.Push(tgt)
+			}
+			assert.Equal(t, len(tc.mockTargets), tc.mockFrontier.Len())
+
+			tc.mockFrontier.Close()
+			for tgt, ok := tc.mockFrontier.Pop(); ok; tgt, ok = tc.mockFrontier.Pop() {
+				res = append(res, tgt.BaseURL)
+			}
+			assert.Equal(t, tc.expectedURLs, res)
+			assert.Equal(t, 0, tc.mockFrontier.Len())
+		})
+	}
+}
+
+func TestFrontier_Snapshot(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mockFrontier Frontier
+	}{
+		{"fifo", NewFIFOFrontier()},
+		{"lifo", NewLIFOFrontier()},
+		{"priority", NewPriorityFrontier(ShallowFirst)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tgts := mockTargets(2, 0, 1)
+			for _, tgt := range tgts {
+				tc.mockFrontier.Push(tgt)
+			}
+
+			assert.Equal(t, tgts, tc.mockFrontier.Snapshot())
+			assert.Equal(t, len(tgts), tc.mockFrontier.Len())
+		})
+	}
+}
+
+func TestFrontier_Close(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mockFrontier Frontier
+	}{
+		{"fifo", NewFIFOFrontier()},
+		{"lifo", NewLIFOFrontier()},
+		{"priority", NewPriorityFrontier(ShallowFirst)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			popped := make(chan bool)
+			go func() {
+				_, ok := tc.mockFrontier.Pop()
+				popped <- ok
+			}()
+
+			select {
+			case <-popped:
+				t.Errorf("%s: Pop returned on an empty Frontier", tc.name)
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			tc.mockFrontier.Close()
+			select {
+			case ok := <-popped:
+				assert.False(t, ok)
+			case <-time.After(1 * time.Second):
+				t.Errorf("%s timeout", tc.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package crawler
+
+import (
+	"sync"
+
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+// Terminator is an `interface` deciding when a crawl shared between several
+// `*crawler.Crawler`s is over. Its `Done` channel is closed once every one of
+// them is idle and no `*domain.Target` is on its way to any of them.
+type Terminator interface {
+	Done() <-chan struct{}
+}
+
+// WithTerminator makes a `*crawler.Crawler` keep running when it has nothing
+// left to crawl, waiting for `*domain.Target`s given to `Crawler.Inject`,
+// until the `Done` channel of `t` is closed.
+func WithTerminator(t Terminator) func(*Crawler) {
+	return func(c *Crawler) { c.terminator = t }
+}
+
+// Inject adds `targets` to the crawl, e.g. when they are received from another
+// `*crawler.Crawler`. They are crawled as soon as possible.
+//
+// NOTE: This function is thread-safe and can be called while `c.Run` is
+// running.
+func (c *Crawler) Inject(targets ...*domain.Target) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inject(targets)
+}
+
+// inject adds `targets` to `c.inbox` and wakes `c.Run` up.
+//
+// NOTE: This function is not thread-safe.
+func (c *Crawler) inject(targets []*domain.Target) {
+	c.inbox = append(c.inbox, targets...)
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Idle returns `true` if `c` is waiting for its `crawler.Terminator` with
+// nothing left to crawl.
+//
+// NOTE: This function is thread-safe.
+func (c *Crawler) Idle() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.idle && len(c.inbox) == 0
+}
+
+// drainInbox moves the `*domain.Target`s given to `c.Inject` to
+// `c.urlFrontier` and accounts for them in `wg`. It returns `true` if there
+// was any. Otherwise, when `empty` is `true` because the pipeline is empty, it
+// marks `c` as idle if it has a `crawler.Terminator` or as closed if it does
+// not, so that `c.Seed` cannot add `*domain.Target`s that would never be
+// crawled.
+//
+// NOTE: Since `wg.Add` cannot be called while `wg.Wait` may return, this
+// function must only be called by a goroutine holding a `*domain.Target` of
+// the pipeline or once `wg.Wait` has returned.
+//
+// NOTE: This function is thread-safe.
+func (c *Crawler) drainInbox(wg *sync.WaitGroup, empty bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.inbox) == 0 {
+		if empty && c.terminator != nil {
+			c.idle = true
+		} else if empty {
+			c.closed = true
+		}
+		return false
+	}
+
+	wg.Add(len(c.inbox))
+	for _, t := range c.inbox {
+		c.urlFrontier.Push(t)
+	}
+	c.inbox = nil
+	c.idle = false
+	c.cond.Signal()
+	return true
+}
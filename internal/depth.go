@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"context"
+	"sync"
+
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+// depthStage is the name used by `*internal.DepthLimiter` to notify events.
+const depthStage = "depth"
+
+// DepthLimiter is a `struct` discarding pages located too many links away from
+// the seed of the crawl.
+type DepthLimiter struct {
+	Reporter
+
+	maxDepth int
+}
+
+// NewDepthLimiter returns a new `*internal.DepthLimiter` letting through
+// `*domain.Target`s with a `Depth` lower or equal to `maxDepth`.
+func NewDepthLimiter(maxDepth int) *DepthLimiter {
+	return &DepthLimiter{maxDepth: maxDepth}
+}
+
+// IsTooDeep returns `true` if `t` is located beyond `d.maxDepth`, `false`
+// otherwise.
+func (d *DepthLimiter) IsTooDeep(t *domain.Target) bool {
+	return t.Depth > d.maxDepth
+}
+
+// Pipe connects `in` and `out` together. Any `*domain.Target` received from
+// `in` will be discarded if it is located beyond `d.maxDepth`.
+//
+// NOTE: This function will loop over a channel until `in` is closed. After that
+// it will close `out`.
+func (d *DepthLimiter) Pipe(ctx context.Context, wg *sync.WaitGroup, in <-chan *domain.Target, out chan<- *domain.Target) {
+	defer close(out)
+
+	for t := range in {
+		d.NotifyEnter(depthStage, t)
+		if ctx.Err() != nil {
+			d.NotifyDiscard(depthStage, t, ReasonCancelled)
+			wg.Done()
+		} else if d.IsTooDeep(t) {
+			d.NotifyDiscard(depthStage, t, ReasonTooDeep)
+			wg.Done()
+		} else {
+			d.NotifyLeave(depthStage, t)
+			out <- t
+		}
+	}
+}
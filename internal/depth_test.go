@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+func TestDepthLimiter_NewDepthLimiter(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mockMaxDepth int
+	}{
+		{"regular", 3},
+		{"seedOnly", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.mockMaxDepth, NewDepthLimiter(tc.mockMaxDepth).maxDepth)
+		})
+	}
+}
+
+func TestDepthLimiter_IsTooDeep(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mockMaxDepth int
+		mockTarget   *domain.Target
+		expected     bool
+	}{
+		{
+			"seed",
+			0,
+			&domain.Target{BaseURL: "https://www.youtube.com"},
+			false,
+		},
+		{
+			"underLimit",
+			2,
+			&domain.Target{BaseURL: "https://www.youtube.com/watch?v=hPtSmbnlEOo", Depth: 1},
+			false,
+		},
+		{
+			"atLimit",
+			2,
+			&domain.Target{BaseURL: "https://www.youtube.com/watch?v=hPtSmbnlEOo", Depth: 2},
+			false,
+		},
+		{
+			"beyondLimit",
+			2,
+			&domain.Target{BaseURL: "https://www.youtube.com/watch?v=hPtSmbnlEOo", Depth: 3},
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, NewDepthLimiter(tc.mockMaxDepth).IsTooDeep(tc.mockTarget))
+		})
+	}
+}
+
+func TestDepthLimiter_Pipe(t *testing.T) {
+	testCases := []struct {
+		name           string
+		mockMaxDepth   int
+		mockTarget     *domain.Target
+		expectedTarget *domain.Target
+	}{
+		{
+			"regular",
+			1,
+			&domain.Target{BaseURL: "https://www.depth.com/one", Depth: 1},
+			&domain.Target{BaseURL: "https://www.depth.com/one", Depth: 1},
+		},
+		{
+			"tooDeep",
+			1,
+			&domain.Target{BaseURL: "https://www.depth.com/one/two", Depth: 2},
+			nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewDepthLimiter(tc.mockMaxDepth)
+
+			inChan := make(chan *domain.Target)
+			outChan := make(chan *domain.Target)
+			wg := sync.WaitGroup{}
+			wg.Add(1)
+
+			go d.Pipe(context.Background(), &wg, inChan, outChan)
+			inChan <- tc.mockTarget
+
+			select {
+			case res := <-outChan:
+				assert.Equal(t, tc.expectedTarget, res)
+			case <-time.After(1 * time.Second):
+				if tc.expectedTarget != nil {
+					t.Errorf("%s timeout", tc.name)
+				}
+				wg.Wait()
+			}
+		})
+	}
+}
@@ -1,13 +1,76 @@
 package domain
 
+import "net/http"
+
 // Target is a `struct` representing the address of web page to scrape and its
 // content.
+//
+// `Depth` is the number of links followed from the seed to reach this page and
+// `Parent` the URL of the page where this link has been found. A seed has a
+// `Depth` of 0 and no `Parent`.
+//
+// `StatusCode` and `Header` are the status code and the headers of the
+// response received when fetching this page. `StatusCode` is 0 until then.
+//
+// `Redirects` is the chain of redirects followed when fetching this page, in
+// order, so that `StatusCode`, `Header` and `Content` are those of the last
+// `Location`.
+//
+// `Unchanged` is `true` if `Content` has not changed since a previous crawl,
+// in which case it may not have been downloaded again and be left empty.
+//
+// `Skipped` is `true` if the body of this page has not been downloaded because
+// of its `Content-Type` or its URL, and `Truncated` is `true` if it has only
+// been partially downloaded because of its size.
+//
+// `Asset` is `true` if this page is an asset, e.g. an image or an archive,
+// that has only been checked rather than downloaded, see
+// `internal.WithAssetCheck`.
+//
+// `Attempts` is the number of times fetching this page has been attempted.
 type Target struct {
-	BaseURL string
-	Content []byte
+	BaseURL    string
+	Content    []byte
+	Depth      int
+	Parent     string
+	StatusCode int
+	Header     http.Header
+	Redirects  []Redirect
+	Unchanged  bool
+	Skipped    bool
+	Truncated  bool
+	Asset      bool
+	Attempts   int
+}
+
+// Redirect is a `struct` representing a web page located at `URL` answered
+// with `StatusCode`, redirecting to `Location`.
+type Redirect struct {
+	URL        string
+	StatusCode int
+	Location   string
 }
 
 // NewTarget returns a new `*domain.Target`.
 func NewTarget(baseURL string) *Target {
 	return &Target{BaseURL: baseURL}
 }
+
+// NewChildTarget returns a new `*domain.Target` located at `baseURL` and found
+// in `parent`.
+func NewChildTarget(baseURL string, parent *Target) *Target {
+	return &Target{
+		BaseURL: baseURL,
+		Depth:   parent.Depth + 1,
+		Parent:  parent.BaseURL,
+	}
+}
+
+// FinalURL returns the URL `t` has been fetched from once its `t.Redirects`
+// have been followed, or `t.BaseURL` if there is none.
+func (t *Target) FinalURL() string {
+	if len(t.Redirects) == 0 {
+		return t.BaseURL
+	}
+	return t.Redirects[len(t.Redirects)-1].Location
+}
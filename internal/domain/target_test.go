@@ -25,3 +25,62 @@ func TestTarget_NewTarget(t *testing.T) {
 		})
 	}
 }
+
+func TestTarget_NewChildTarget(t *testing.T) {
+	testCases := []struct {
+		name           string
+		mockBaseURL    string
+		mockParent     *Target
+		expectedTarget *Target
+	}{
+		{
+			"seed",
+			"https://consul.io/docs",
+			&Target{BaseURL: "https://consul.io"},
+			&Target{BaseURL: "https://consul.io/docs", Depth: 1, Parent: "https://consul.io"},
+		},
+		{
+			"deep",
+			"https://consul.io/docs/agent",
+			&Target{BaseURL: "https://consul.io/docs", Depth: 1, Parent: "https://consul.io"},
+			&Target{BaseURL: "https://consul.io/docs/agent", Depth: 2, Parent: "https://consul.io/docs"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedTarget, NewChildTarget(tc.mockBaseURL, tc.mockParent))
+		})
+	}
+}
+
+func TestTarget_FinalURL(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mockTarget  *Target
+		expectedURL string
+	}{
+		{
+			"noRedirect",
+			&Target{BaseURL: "https://consul.io"},
+			"https://consul.io",
+		},
+		{
+			"redirects",
+			&Target{
+				BaseURL: "http://consul.io",
+				Redirects: []Redirect{
+					{URL: "http://consul.io", StatusCode: 301, Location: "https://consul.io"},
+					{URL: "https://consul.io", StatusCode: 302, Location: "https://consul.io/docs"},
+				},
+			},
+			"https://consul.io/docs",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedURL, tc.mockTarget.FinalURL())
+		})
+	}
+}
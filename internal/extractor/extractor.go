@@ -2,16 +2,22 @@ package extractor
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"log"
 	"net/url"
+	"runtime"
 	"strings"
 	"sync"
 
+	"github.com/timtosi/mcrawler/internal"
 	"github.com/timtosi/mcrawler/internal/domain"
 	"golang.org/x/net/html"
 )
 
+// stage is the name used by `*extractor.Extractor` to report errors and notify
+// events.
+const stage = "extractor"
+
 // validateScheme returns `true` `scheme` correspond to `http`, `https` or `ftp`
 // or `false` otherwise.
 func validateScheme(scheme string) bool {
@@ -61,18 +67,34 @@ type CheckFunc func(html.Token, html.TokenType) string
 // Extractor is a `struct` that extracts links found in a web page according to
 // the results of its inner `CheckFunc` functions.
 type Extractor struct {
-	cf []CheckFunc
+	internal.Reporter
+
+	cf          []CheckFunc
+	concurrency int
 }
 
-// NewExtractor returns a new `*extractor.Extractor`.
-func NewExtractor(checkFuncs ...CheckFunc) *Extractor {
-	e := Extractor{cf: make([]CheckFunc, 0)}
+// NewExtractor returns a new `*extractor.Extractor` looking for links with
+// `checkFuncs` that can be configured through `opts` functions.
+func NewExtractor(checkFuncs []CheckFunc, opts ...func(*Extractor)) *Extractor {
+	e := Extractor{cf: make([]CheckFunc, 0), concurrency: runtime.NumCPU()}
 	e.cf = append(e.cf, checkFuncs...)
+
+	for _, opt := range opts {
+		opt(&e)
+	}
 	return &e
 }
 
+// WithConcurrency makes a `*extractor.Extractor` parse at most `n` web pages
+// at the same time instead of one per CPU. No limit is applied when `n` is
+// lower than 1.
+func WithConcurrency(n int) func(*Extractor) {
+	return func(e *Extractor) { e.concurrency = n }
+}
+
 // ExtractLinks extracts, cleans and returns a `[]string` of links found in
-// `content` and matching any `e.cf` function.
+// `content` and matching any `e.cf` function. Links that cannot be cleaned are
+// reported as `*internal.CrawlError`s.
 func (e *Extractor) ExtractLinks(baseURL string, content []byte) []string {
 	var links []string
 	rawLink := ""
@@ -91,7 +113,7 @@ func (e *Extractor) ExtractLinks(baseURL string, content []byte) []string {
 				uniqueLinks[link] = true
 				break
 			} else {
-				log.Printf("ExtractLinks: %s => %v ", link, err)
+				e.ReportError(stage, baseURL, err)
 			}
 
 		}
@@ -103,24 +125,41 @@ func (e *Extractor) ExtractLinks(baseURL string, content []byte) []string {
 	return links
 }
 
+// ConsumesTargets implements the `internal.Consumer` interface.
+func (e *Extractor) ConsumesTargets() {}
+
 // Pipe connects `in` and `out` together. Any `*domain.Target` received from
-// `in` will be parsed and extracted links will be sent to `out`.
+// `in` will be parsed and extracted links will be sent to `out` as its
+// children.
+//
+// NOTE: `*domain.Target`s received from `in` are not sent to `out` and leave
+// the pipeline once every one of their children has been sent to `out`.
+//
+// NOTE: At most `e.concurrency` web pages are parsed at the same time, see
+// `internal.ForEach`. Links are sent to `out` one after the other, so that a
+// slow `internal.Pipe` downstream slows down the parsing as well.
 //
 // NOTE: This function will loop over a channel until `in` is closed. After that
-// it will close `out`.
-func (e *Extractor) Pipe(wg *sync.WaitGroup, in <-chan *domain.Target, out chan<- *domain.Target) {
+// it will close `out` once every web page has been parsed.
+func (e *Extractor) Pipe(ctx context.Context, wg *sync.WaitGroup, in <-chan *domain.Target, out chan<- *domain.Target) {
 	defer close(out)
 
-	for t := range in {
-		wg.Add(1)
-		go func(tgt *domain.Target) {
-			links := e.ExtractLinks(tgt.BaseURL, tgt.Content)
-			for _, link := range links {
-				wg.Add(1)
-				go func(l string) { out <- domain.NewTarget(l) }(link)
-			}
+	internal.ForEach(e.concurrency, in, func(t *domain.Target) {
+		e.NotifyEnter(stage, t)
+		if ctx.Err() != nil {
+			e.NotifyDiscard(stage, t, internal.ReasonCancelled)
 			wg.Done()
-			wg.Done()
-		}(t)
-	}
+			return
+		}
+
+		links := e.ExtractLinks(t.FinalURL(), t.Content)
+		wg.Add(len(links))
+		for _, link := range links {
+			child := domain.NewChildTarget(link, t)
+			e.NotifyEmit(stage, child)
+			out <- child
+		}
+		e.NotifyLeave(stage, t)
+		wg.Done()
+	})
 }
@@ -1,10 +1,13 @@
 package extractor
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"runtime"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -151,7 +154,7 @@ func TestExtractor_NewExtractor(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			assert.NotPanics(t, func() { NewExtractor(tc.mockCheckFuncs...) })
+			assert.NotPanics(t, func() { NewExtractor(tc.mockCheckFuncs) })
 		})
 	}
 }
@@ -325,7 +328,7 @@ func TestExtractor_ExtractLinks(t *testing.T) {
 				t.Errorf("%s: %v", tc.name, err)
 			}
 
-			e := NewExtractor(tc.mockCheckFuncs...)
+			e := NewExtractor(tc.mockCheckFuncs)
 			res := e.ExtractLinks(tc.mockBaseURL, content)
 			assert.ElementsMatch(t, tc.expectedLinks, res)
 		})
@@ -505,19 +508,23 @@ func TestExtractor_Pipe(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			var res []*domain.Target
-			e := NewExtractor(tc.mockCheckFuncs...)
+			e := NewExtractor(tc.mockCheckFuncs)
 
 			inChan := make(chan *domain.Target)
 			outChan := make(chan *domain.Target)
 			wg := sync.WaitGroup{}
 			wg.Add(1)
 
-			go e.Pipe(&wg, inChan, outChan)
+			go e.Pipe(context.Background(), &wg, inChan, outChan)
 			tgt, err := mockTarget(tc.mockBaseURL, tc.mockContentPath)
 			if err != nil {
 				log.Fatalf("%s: %v", tc.name, err)
 			}
 			inChan <- tgt
+			for _, expected := range tc.expectedTargets {
+				expected.Depth = tgt.Depth + 1
+				expected.Parent = tgt.BaseURL
+			}
 
 		loop:
 			select {
@@ -531,6 +538,102 @@ func TestExtractor_Pipe(t *testing.T) {
 	}
 }
 
+func TestExtractor_WithConcurrency(t *testing.T) {
+	testCases := []struct {
+		name            string
+		mockConcurrency int
+	}{
+		{"unbounded", 0},
+		{"sequential", 1},
+		{"bounded", 4},
+	}
+
+	assert.Equal(t, runtime.NumCPU(), NewExtractor(nil).concurrency)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var res []string
+			e := NewExtractor([]CheckFunc{GetLinkBasic}, WithConcurrency(tc.mockConcurrency))
+			assert.Equal(t, tc.mockConcurrency, e.concurrency)
+
+			inChan := make(chan *domain.Target)
+			outChan := make(chan *domain.Target)
+			wg := sync.WaitGroup{}
+			wg.Add(10)
+
+			go e.Pipe(context.Background(), &wg, inChan, outChan)
+			go func() {
+				for i := 0; i < 10; i++ {
+					tgt := domain.NewTarget(fmt.Sprintf("http://www.page%d.com", i))
+					tgt.Content = []byte(`<a href="/next">next</a>`)
+					inChan <- tgt
+				}
+				close(inChan)
+			}()
+
+			for tgt := range outChan {
+				res = append(res, tgt.BaseURL)
+				wg.Done()
+			}
+			wg.Wait()
+			assert.Len(t, res, 10)
+		})
+	}
+}
+
+// mockLinkPage is a helper function only used for test purposes. It returns
+// the content of a web page holding `n` different links.
+func mockLinkPage(n int) []byte {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, `<a href="/link%d">link</a>`, i)
+	}
+	return []byte(sb.String())
+}
+
+func BenchmarkExtractor_Pipe(b *testing.B) {
+	benchCases := []struct {
+		name            string
+		mockConcurrency int
+	}{
+		{"unbounded", 0},
+		{"concurrency4", 4},
+	}
+
+	content := mockLinkPage(1000)
+	for _, bc := range benchCases {
+		b.Run(bc.name, func(b *testing.B) {
+			var peak int
+			e := NewExtractor([]CheckFunc{GetLinkBasic}, WithConcurrency(bc.mockConcurrency))
+			inChan := make(chan *domain.Target)
+			outChan := make(chan *domain.Target)
+			wg := sync.WaitGroup{}
+			wg.Add(b.N)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			go e.Pipe(context.Background(), &wg, inChan, outChan)
+			go func() {
+				for i := 0; i < b.N; i++ {
+					tgt := domain.NewTarget("http://www.links.com")
+					tgt.Content = content
+					inChan <- tgt
+				}
+				close(inChan)
+			}()
+			for range outChan {
+				if n := runtime.NumGoroutine(); n > peak {
+					peak = n
+				}
+				wg.Done()
+			}
+			wg.Wait()
+			b.StopTimer()
+
+			b.ReportMetric(float64(peak), "goroutines")
+		})
+	}
+}
+
 func TestMain(m *testing.M) {
 	log.SetOutput(ioutil.Discard)
 	defer log.SetOutput(os.Stderr)
@@ -1,8 +1,8 @@
 package internal
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"net/url"
 	"sync"
 
@@ -23,53 +23,83 @@ func getHost(link string) (string, error) {
 	return urlStruct.Host, nil
 }
 
+// followerStage is the name used by `*internal.Follower` to report errors and
+// notify events.
+const followerStage = "follower"
+
 // Follower is a `struct` controlling that the pages crawled are only located
-// on the `originHost` host.
+// on one of the `originHosts` hosts.
 type Follower struct {
-	originHost string
+	Reporter
+
+	originHosts map[string]bool
 }
 
-// NewFollower returns a new `*crawler.Follower` or an `error` if `link` cannot
-// be parsed properly by `url.Parse`.
-func NewFollower(originURL string) (*Follower, error) {
-	host, err := getHost(originURL)
-	if err != nil {
-		return nil, fmt.Errorf("NewFollower: %v", err)
+// NewFollower returns a new `*crawler.Follower` following the hosts of
+// `originURLs` or an `error` if none is given or one of them cannot be parsed
+// properly by `url.Parse`.
+func NewFollower(originURLs ...string) (*Follower, error) {
+	if len(originURLs) == 0 {
+		return nil, fmt.Errorf("NewFollower: no origin URL given")
+	}
+
+	f := &Follower{originHosts: make(map[string]bool)}
+	for _, originURL := range originURLs {
+		host, err := getHost(originURL)
+		if err != nil {
+			return nil, fmt.Errorf("NewFollower: %v", err)
+		}
+		f.originHosts[host] = true
 	}
-	return &Follower{originHost: host}, nil
+	return f, nil
 }
 
-// IsSameHost ensures that `link` is located on `f.originHost`. It returns
-// `true` if it is the case, `false` otherwise or an `error` if `link` cannot
-// be parsed properly by `url.Parse`.
+// IsSameHost ensures that `link` is located on one of `f.originHosts`. It
+// returns `true` if it is the case, `false` otherwise or an `error` if `link`
+// cannot be parsed properly by `url.Parse`.
 func (f *Follower) IsSameHost(link string) (bool, error) {
 	host, err := getHost(link)
 	if err != nil {
 		return false, fmt.Errorf("IsSameHost: %v", err)
 	}
+	return f.originHosts[host], nil
+}
 
-	if host != f.originHost {
-		return false, nil
+// CheckRedirect implements the `internal.RedirectChecker` interface. It
+// returns `internal.ReasonOffHost` if `link` is not located on one of
+// `f.originHosts`, or `internal.ReasonInvalidURL` if it cannot be parsed.
+func (f *Follower) CheckRedirect(link string) string {
+	if ok, err := f.IsSameHost(link); err != nil {
+		return ReasonInvalidURL
+	} else if !ok {
+		return ReasonOffHost
 	}
-	return true, nil
+	return ""
 }
 
 // Pipe connects `in` and `out` together. Any `*domain.Target` received from
-// `in` will be checked against `f.originHost` and be discarded if its host does
-// not match `f.originHost`.
+// `in` will be checked against `f.originHosts` and be discarded if its host
+// does not match any of them.
 //
 // NOTE: This function will loop over a channel until `in` is closed. After that
 // it will close `out`.
-func (f *Follower) Pipe(wg *sync.WaitGroup, in <-chan *domain.Target, out chan<- *domain.Target) {
+func (f *Follower) Pipe(ctx context.Context, wg *sync.WaitGroup, in <-chan *domain.Target, out chan<- *domain.Target) {
 	defer close(out)
 
 	for t := range in {
-		if ok, err := f.IsSameHost(t.BaseURL); err != nil {
-			log.Printf("Follower: %f", err)
+		f.NotifyEnter(followerStage, t)
+		if ctx.Err() != nil {
+			f.NotifyDiscard(followerStage, t, ReasonCancelled)
+			wg.Done()
+		} else if ok, err := f.IsSameHost(t.BaseURL); err != nil {
+			f.ReportError(followerStage, t.BaseURL, err)
+			f.NotifyDiscard(followerStage, t, ReasonInvalidURL)
 			wg.Done()
 		} else if !ok {
+			f.NotifyDiscard(followerStage, t, ReasonOffHost)
 			wg.Done()
 		} else {
+			f.NotifyLeave(followerStage, t)
 			out <- t
 		}
 	}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"log"
 	"sync"
 	"testing"
@@ -54,7 +55,37 @@ func TestFollower_NewFollower(t *testing.T) {
 			f, err := NewFollower(tc.mockOriginHost)
 			tc.expectedAssertFunc(t, err)
 			if f != nil {
-				assert.Equal(t, tc.expectOriginHost, f.originHost)
+				assert.Equal(t, map[string]bool{tc.expectOriginHost: true}, f.originHosts)
+			}
+		})
+	}
+}
+
+func TestFollower_NewFollower_multiple(t *testing.T) {
+	testCases := []struct {
+		name              string
+		mockOriginURLs    []string
+		expectOriginHosts map[string]bool
+		expectedErr       bool
+	}{
+		{"none", nil, nil, true},
+		{
+			"severalHosts",
+			[]string{"https://www.a.com", "https://docs.a.com/intro", "https://www.a.com/about"},
+			map[string]bool{"www.a.com": true, "docs.a.com": true},
+			false,
+		},
+		{"oneInvalid", []string{"https://www.a.com", "/yes"}, nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := NewFollower(tc.mockOriginURLs...)
+			assert.Equal(t, tc.expectedErr, err != nil)
+			if f != nil {
+				assert.Equal(t, tc.expectOriginHosts, f.originHosts)
+				ok, _ := f.IsSameHost("https://docs.a.com/page")
+				assert.True(t, ok)
 			}
 		})
 	}
@@ -183,7 +214,7 @@ func TestFollower_Pipe(t *testing.T) {
 			wg := sync.WaitGroup{}
 			wg.Add(1)
 
-			go f.Pipe(&wg, inChan, outChan)
+			go f.Pipe(context.Background(), &wg, inChan, outChan)
 			inChan <- tc.mockTarget
 
 			select {
@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// identity is a `struct` implementing the `http.RoundTripper` interface. It
+// adds the `User-Agent`, the headers and the credentials of a
+// `*internal.Worker` to every request sent through `base`, including the
+// redirects it follows, so that per host settings only apply to the host each
+// request is actually sent to.
+type identity struct {
+	base http.RoundTripper
+
+	userAgent   string
+	headers     http.Header
+	hostHeaders map[string]http.Header
+	credentials map[string]string
+}
+
+// newIdentity returns a new `*internal.identity` sending requests through
+// `base`.
+func newIdentity(base http.RoundTripper) *identity {
+	return &identity{
+		base:        base,
+		headers:     http.Header{},
+		hostHeaders: make(map[string]http.Header),
+		credentials: make(map[string]string),
+	}
+}
+
+// hostKey returns the key under which the settings of `host`, with or without
+// port, are stored.
+func hostKey(host string) string {
+	return strings.ToLower(host)
+}
+
+// addHostHeader makes `id` add the header `key` with `value` to the requests
+// sent to `host`.
+func (id *identity) addHostHeader(host, key, value string) {
+	k := hostKey(host)
+	if id.hostHeaders[k] == nil {
+		id.hostHeaders[k] = http.Header{}
+	}
+	id.hostHeaders[k].Add(key, value)
+}
+
+// forHost returns the value stored in `m` for the host of `req`, looked up
+// first with its port and then without it.
+func forHost(m map[string]string, req *http.Request) (string, bool) {
+	if v, ok := m[hostKey(req.URL.Host)]; ok {
+		return v, true
+	}
+	v, ok := m[hostKey(req.URL.Hostname())]
+	return v, ok
+}
+
+// basicAuth returns the value of the `Authorization` header of the HTTP Basic
+// authentication of `user` with `password`.
+func basicAuth(user, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
+
+// RoundTrip implements the `http.RoundTripper` interface.
+//
+// NOTE: Settings given for a host with its port take precedence over the ones
+// given without it, which take precedence over the ones given for every host.
+//
+// NOTE: `req` is left untouched and a copy of it is sent instead.
+func (id *identity) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+
+	if len(id.userAgent) != 0 {
+		req.Header.Set("User-Agent", id.userAgent)
+	}
+	for key, values := range id.headers {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	for _, host := range []string{req.URL.Hostname(), req.URL.Host} {
+		for key, values := range id.hostHeaders[hostKey(host)] {
+			req.Header[key] = append([]string(nil), values...)
+		}
+	}
+	if auth, ok := forHost(id.credentials, req); ok {
+		req.Header.Set("Authorization", auth)
+	}
+	return id.base.RoundTrip(req)
+}
@@ -1,24 +1,46 @@
 package mapper
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/timtosi/mcrawler/internal"
 	"github.com/timtosi/mcrawler/internal/domain"
 )
 
+// stage is the name used by `*mapper.Mapper` to notify events.
+const stage = "mapper"
+
 // Mapper is a `struct` used for rendering a Site Map.
 type Mapper struct {
-	siteMap []string
-	mu      *sync.RWMutex
+	internal.Reporter
+
+	siteMap      []string
+	lastModified func(string) (time.Time, bool)
+	mu           *sync.RWMutex
 }
 
-// NewMapper returns a new `*mapper.Mapper`.
-func NewMapper() *Mapper {
-	return &Mapper{
+// NewMapper returns a new `*mapper.Mapper` that can be configured through
+// `opts` functions.
+func NewMapper(opts ...func(*Mapper)) *Mapper {
+	m := &Mapper{
 		siteMap: make([]string, 0),
 		mu:      &sync.RWMutex{},
 	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// WithLastModified makes a `*mapper.Mapper` render the last time every web
+// page has been modified as returned by `fn`, e.g. `cache.Cache.LastModified`,
+// when it is known.
+func WithLastModified(fn func(link string) (time.Time, bool)) func(*Mapper) {
+	return func(m *Mapper) { m.lastModified = fn }
 }
 
 // Add adds `link` to `m.siteMap`.
@@ -57,21 +79,34 @@ func (m *Mapper) Render() {
 	for _, k := range m.siteMap {
 		fmt.Println("\t<url>")
 		fmt.Printf("\t\t<loc>%s</loc>\n", k)
+		if m.lastModified != nil {
+			if lm, ok := m.lastModified(k); ok {
+				fmt.Printf("\t\t<lastmod>%s</lastmod>\n", lm.UTC().Format(time.RFC3339))
+			}
+		}
 		fmt.Println("\t</url>")
 	}
 	fmt.Println("</urlset>")
 }
 
 // Pipe connects `in` and `out` together. Any `*domain.Target` received from
-// `in` that have been processed will be added to `m.siteMap`.
+// `in` that have been processed will be added to `m.siteMap`, under the URL
+// it has been redirected to if any.
 //
 // NOTE: This function will loop over a channel until `in` is closed. After that
 // it will close `out`.
-func (m *Mapper) Pipe(wg *sync.WaitGroup, in <-chan *domain.Target, out chan<- *domain.Target) {
+func (m *Mapper) Pipe(ctx context.Context, wg *sync.WaitGroup, in <-chan *domain.Target, out chan<- *domain.Target) {
 	defer close(out)
 
 	for t := range in {
-		m.Add(t.BaseURL)
+		m.NotifyEnter(stage, t)
+		if ctx.Err() != nil {
+			m.NotifyDiscard(stage, t, internal.ReasonCancelled)
+			wg.Done()
+			continue
+		}
+		m.Add(t.FinalURL())
+		m.NotifyLeave(stage, t)
 		out <- t
 	}
 }
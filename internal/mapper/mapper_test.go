@@ -2,6 +2,7 @@ package mapper
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"io/ioutil"
 	"os"
@@ -94,25 +95,37 @@ func TestMapper_SiteMap(t *testing.T) {
 
 func TestMapper_Render(t *testing.T) {
 	testCases := []struct {
-		name           string
-		mockSiteMapRaw []string
-		expected       string
+		name             string
+		mockSiteMapRaw   []string
+		mockLastModified func(string) (time.Time, bool)
+		expected         string
 	}{
 		{
 			"empty",
 			[]string{},
+			nil,
 			"testdata/mapper_render_empty.xml",
 		},
 		{
 			"regular_single",
 			[]string{"https://fakeMapper.com"},
+			nil,
 			"testdata/mapper_render_single.xml",
 		},
 		{
 			"regular_multiple",
 			[]string{"https://fakeMapper.com", "https://notaSeen.com"},
+			nil,
 			"testdata/mapper_render_multiple.xml",
 		},
+		{
+			"lastModified",
+			[]string{"https://fakeMapper.com", "https://notaSeen.com"},
+			func(link string) (time.Time, bool) {
+				return time.Date(2019, 3, 14, 15, 9, 26, 0, time.UTC), link == "https://fakeMapper.com"
+			},
+			"testdata/mapper_render_lastmod.xml",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -127,7 +140,7 @@ func TestMapper_Render(t *testing.T) {
 			}
 			os.Stdout = w
 
-			m := NewMapper()
+			m := NewMapper(WithLastModified(tc.mockLastModified))
 			m.siteMap = tc.mockSiteMapRaw
 
 			assert.NotPanics(t, func() { m.Render() })
@@ -179,7 +192,7 @@ func TestMapper_Pipe(t *testing.T) {
 			outChan := make(chan *domain.Target)
 			wg := sync.WaitGroup{}
 
-			go m.Pipe(&wg, inChan, outChan)
+			go m.Pipe(context.Background(), &wg, inChan, outChan)
 			inChan <- tc.mockTarget
 
 			select {
@@ -0,0 +1,226 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/timtosi/mcrawler/internal"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the fetch latency
+// histogram.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// gauge is a `struct` representing a value computed each time metrics are
+// collected.
+type gauge struct {
+	name  string
+	help  string
+	value func() float64
+}
+
+// label is a `struct` used as a key for counters sharing a name.
+type label struct {
+	stage string
+	value string
+}
+
+// Metrics is a `struct` collecting statistics about a crawl from the
+// `internal.Event`s of the pipeline. It implements both the
+// `internal.Observer` interface, so that it can be given to
+// `crawler.WithObserver`, and the `http.Handler` interface, so that these
+// statistics can be served in the Prometheus text format.
+type Metrics struct {
+	buckets []float64
+	gauges  []gauge
+
+	pages       int64
+	fetchErrors int64
+	bytes       int64
+	statuses    map[int]int64
+	latency     []int64
+	latencySum  float64
+	latencyN    int64
+	stages      map[label]int64
+	dropped     map[label]int64
+	mu          *sync.Mutex
+}
+
+// NewMetrics returns a new `*metrics.Metrics` that can be configured through
+// `opts` functions. The number of running goroutines is always collected.
+func NewMetrics(opts ...func(*Metrics)) *Metrics {
+	m := &Metrics{
+		buckets:  DefaultBuckets,
+		statuses: make(map[int]int64),
+		stages:   make(map[label]int64),
+		dropped:  make(map[label]int64),
+		mu:       &sync.Mutex{},
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.latency = make([]int64, len(m.buckets))
+	m.gauges = append(m.gauges, gauge{
+		"mcrawler_goroutines",
+		"Number of goroutines running.",
+		func() float64 { return float64(runtime.NumGoroutine()) },
+	})
+	return m
+}
+
+// WithBuckets makes a `*metrics.Metrics` use `buckets`, sorted upper bounds in
+// seconds, for the fetch latency histogram.
+func WithBuckets(buckets ...float64) func(*Metrics) {
+	return func(m *Metrics) { m.buckets = buckets }
+}
+
+// WithGauge makes a `*metrics.Metrics` serve the value returned by `value`
+// under `name`, e.g. the length of the frontier of a `*crawler.Crawler`.
+//
+// NOTE: `value` is called concurrently each time metrics are served and must
+// therefore be thread-safe.
+func WithGauge(name, help string, value func() float64) func(*Metrics) {
+	return func(m *Metrics) { m.gauges = append(m.gauges, gauge{name, help, value}) }
+}
+
+// Observe implements the `internal.Observer` interface.
+//
+// NOTE: This function is thread-safe.
+func (m *Metrics) Observe(e internal.Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch e.Kind {
+	case internal.EventFetch:
+		m.observeFetch(e)
+	case internal.EventDiscard:
+		m.dropped[label{e.Stage, e.Reason}]++
+	default:
+		m.stages[label{e.Stage, e.Kind.String()}]++
+	}
+}
+
+// observeFetch updates the fetch statistics of `m` with `e`.
+func (m *Metrics) observeFetch(e internal.Event) {
+	if e.Status == 0 {
+		m.fetchErrors++
+	} else {
+		m.pages++
+		m.bytes += int64(e.Bytes)
+		m.statuses[e.Status]++
+	}
+
+	d := e.Duration.Seconds()
+	for i, bound := range m.buckets {
+		if d <= bound {
+			m.latency[i]++
+		}
+	}
+	m.latencySum += d
+	m.latencyN++
+}
+
+// WriteTo writes the metrics of `m` to `w` in the Prometheus text format and
+// returns the number of bytes written or an `error` if something bad occurs.
+//
+// NOTE: This function is thread-safe.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	ew := &errWriter{w: w}
+
+	m.mu.Lock()
+	ew.header("mcrawler_pages_fetched_total", "counter", "Number of responses received.")
+	ew.printf("mcrawler_pages_fetched_total %d\n", m.pages)
+	ew.header("mcrawler_fetch_errors_total", "counter", "Number of requests that received no response.")
+	ew.printf("mcrawler_fetch_errors_total %d\n", m.fetchErrors)
+	ew.header("mcrawler_bytes_downloaded_total", "counter", "Number of bytes of response bodies downloaded.")
+	ew.printf("mcrawler_bytes_downloaded_total %d\n", m.bytes)
+
+	ew.header("mcrawler_responses_total", "counter", "Number of responses received per status code.")
+	codes := make([]int, 0, len(m.statuses))
+	for code := range m.statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		ew.printf("mcrawler_responses_total{code=\"%d\"} %d\n", code, m.statuses[code])
+	}
+
+	ew.header("mcrawler_fetch_duration_seconds", "histogram", "Time spent fetching web pages.")
+	for i, bound := range m.buckets {
+		ew.printf("mcrawler_fetch_duration_seconds_bucket{le=\"%s\"} %d\n", formatFloat(bound), m.latency[i])
+	}
+	ew.printf("mcrawler_fetch_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.latencyN)
+	ew.printf("mcrawler_fetch_duration_seconds_sum %s\n", formatFloat(m.latencySum))
+	ew.printf("mcrawler_fetch_duration_seconds_count %d\n", m.latencyN)
+
+	ew.header("mcrawler_stage_events_total", "counter", "Number of targets entering, leaving or emitted by a pipeline stage.")
+	for _, l := range sortedLabels(m.stages) {
+		ew.printf("mcrawler_stage_events_total{stage=%q,event=%q} %d\n", l.stage, l.value, m.stages[l])
+	}
+	ew.header("mcrawler_targets_dropped_total", "counter", "Number of targets discarded per pipeline stage and reason.")
+	for _, l := range sortedLabels(m.dropped) {
+		ew.printf("mcrawler_targets_dropped_total{stage=%q,reason=%q} %d\n", l.stage, l.value, m.dropped[l])
+	}
+	m.mu.Unlock()
+
+	for _, g := range m.gauges {
+		ew.header(g.name, "gauge", g.help)
+		ew.printf("%s %s\n", g.name, formatFloat(g.value()))
+	}
+	return ew.n, ew.err
+}
+
+// ServeHTTP implements the `http.Handler` interface.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// sortedLabels returns the keys of `counters` sorted by stage and value.
+func sortedLabels(counters map[label]int64) []label {
+	labels := make([]label, 0, len(counters))
+	for l := range counters {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].stage != labels[j].stage {
+			return labels[i].stage < labels[j].stage
+		}
+		return labels[i].value < labels[j].value
+	})
+	return labels
+}
+
+// formatFloat returns `f` formatted as expected by the Prometheus text format.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// errWriter is a `struct` writing to `w` until an `error` occurs.
+type errWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+// printf writes to `ew.w` according to `format` unless an `error` occurred.
+func (ew *errWriter) printf(format string, a ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+
+	n, err := fmt.Fprintf(ew.w, format, a...)
+	ew.n += int64(n)
+	ew.err = err
+}
+
+// header writes the `HELP` and `TYPE` lines of the metric `name`.
+func (ew *errWriter) header(name, kind, help string) {
+	ew.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timtosi/mcrawler/internal"
+)
+
+func TestMetrics_Observe(t *testing.T) {
+	testCases := []struct {
+		name          string
+		mockEvents    []internal.Event
+		expectedLines []string
+	}{
+		{
+			"empty",
+			nil,
+			[]string{
+				"mcrawler_pages_fetched_total 0",
+				"mcrawler_fetch_duration_seconds_count 0",
+				"# TYPE mcrawler_goroutines gauge",
+			},
+		},
+		{
+			"fetch",
+			[]internal.Event{
+				{Kind: internal.EventFetch, Stage: "worker", Status: 200, Bytes: 100, Duration: 20 * time.Millisecond},
+				{Kind: internal.EventFetch, Stage: "worker", Status: 404, Bytes: 10, Duration: 2 * time.Second},
+				{Kind: internal.EventFetch, Stage: "worker", Duration: time.Minute},
+			},
+			[]string{
+				"mcrawler_pages_fetched_total 2",
+				"mcrawler_fetch_errors_total 1",
+				"mcrawler_bytes_downloaded_total 110",
+				`mcrawler_responses_total{code="200"} 1`,
+				`mcrawler_responses_total{code="404"} 1`,
+				`mcrawler_fetch_duration_seconds_bucket{le="0.01"} 0`,
+				`mcrawler_fetch_duration_seconds_bucket{le="0.025"} 1`,
+				`mcrawler_fetch_duration_seconds_bucket{le="2.5"} 2`,
+				`mcrawler_fetch_duration_seconds_bucket{le="10"} 2`,
+				`mcrawler_fetch_duration_seconds_bucket{le="+Inf"} 3`,
+				"mcrawler_fetch_duration_seconds_sum 62.02",
+				"mcrawler_fetch_duration_seconds_count 3",
+			},
+		},
+		{
+			"stages",
+			[]internal.Event{
+				{Kind: internal.EventEnter, Stage: "archiver"},
+				{Kind: internal.EventEnter, Stage: "archiver"},
+				{Kind: internal.EventLeave, Stage: "archiver"},
+				{Kind: internal.EventDiscard, Stage: "archiver", Reason: internal.ReasonAlreadySeen},
+				{Kind: internal.EventEmit, Stage: "extractor"},
+				{Kind: internal.EventDiscard, Stage: "follower", Reason: internal.ReasonOffHost},
+			},
+			[]string{
+				`mcrawler_stage_events_total{stage="archiver",event="enter"} 2`,
+				`mcrawler_stage_events_total{stage="archiver",event="leave"} 1`,
+				`mcrawler_stage_events_total{stage="extractor",event="emit"} 1`,
+				`mcrawler_targets_dropped_total{stage="archiver",reason="already-seen"} 1`,
+				`mcrawler_targets_dropped_total{stage="follower",reason="off-host"} 1`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewMetrics()
+			for _, e := range tc.mockEvents {
+				m.Observe(e)
+			}
+
+			buf := &bytes.Buffer{}
+			n, err := m.WriteTo(buf)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(buf.Len()), n)
+			for _, line := range tc.expectedLines {
+				assert.Contains(t, buf.String(), line+"\n")
+			}
+		})
+	}
+}
+
+func TestMetrics_ServeHTTP(t *testing.T) {
+	m := NewMetrics(
+		WithBuckets(1),
+		WithGauge("mcrawler_frontier_length", "Number of targets in the frontier.", func() float64 { return 42 }),
+	)
+	m.Observe(internal.Event{Kind: internal.EventFetch, Status: 200, Duration: time.Second})
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, rec.Body.String(), `mcrawler_fetch_duration_seconds_bucket{le="1"} 1`+"\n")
+	assert.Contains(t, rec.Body.String(), "# TYPE mcrawler_frontier_length gauge\nmcrawler_frontier_length 42\n")
+}
@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"time"
+
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+// EventKind is a named type representing what happened to a `*domain.Target`
+// in a stage of the pipeline.
+type EventKind int
+
+const (
+	// EventEnter is sent when a `*domain.Target` enters a stage.
+	EventEnter EventKind = iota
+	// EventLeave is sent when a `*domain.Target` leaves a stage after being
+	// processed.
+	EventLeave
+	// EventDiscard is sent when a `*domain.Target` is removed from the
+	// pipeline by a stage. The reason is given in `Event.Reason`.
+	EventDiscard
+	// EventEmit is sent when a stage creates a new `*domain.Target`, e.g. a
+	// link found by the `*extractor.Extractor`.
+	EventEmit
+	// EventFetch is sent when a stage performed an HTTP request for a
+	// `*domain.Target`. The outcome is given in `Event.Status`, `Event.Bytes`
+	// and `Event.Duration`.
+	EventFetch
+	// EventRequest is sent when a stage sends an HTTP request for a
+	// `*domain.Target`. It is followed by an `internal.EventFetch` once the
+	// response has been read or the request has failed.
+	EventRequest
+)
+
+// String implements the `fmt.Stringer` interface.
+func (k EventKind) String() string {
+	switch k {
+	case EventEnter:
+		return "enter"
+	case EventLeave:
+		return "leave"
+	case EventDiscard:
+		return "discard"
+	case EventEmit:
+		return "emit"
+	case EventFetch:
+		return "fetch"
+	case EventRequest:
+		return "request"
+	}
+	return "unknown"
+}
+
+// Reasons given in `Event.Reason` by the built-in `internal.Pipe`s and
+// adapters when a `*domain.Target` is discarded.
+const (
+	ReasonAlreadySeen = "already-seen"
+	ReasonOffHost     = "off-host"
+	ReasonInvalidURL  = "invalid-url"
+	ReasonTooDeep     = "too-deep"
+	ReasonRobots      = "robots-disallowed"
+	ReasonFetchFailed = "fetch-failed"
+	ReasonBadStatus   = "bad-status"
+	ReasonBadRedirect = "bad-redirect"
+	ReasonCancelled   = "cancelled"
+	ReasonFiltered    = "filtered"
+	ReasonFailed      = "failed"
+	ReasonForwarded   = "forwarded"
+	ReasonAsset       = "asset"
+)
+
+// Event is a `struct` describing what happened to the `*domain.Target`
+// located at `URL` in the `Stage` of the pipeline.
+//
+// `Status`, `Bytes` and `Duration` are only set for `internal.EventFetch`.
+// `Status` is the status code of the response, or 0 if none was received.
+//
+// NOTE: `Event` holds a copy of the `*domain.Target` fields so that it can be
+// used after the `*domain.Target` has moved on in the pipeline.
+type Event struct {
+	Kind   EventKind
+	Stage  string
+	URL    string
+	Parent string
+	Depth  int
+	Reason string
+	Time   time.Time
+
+	Status   int
+	Bytes    int
+	Duration time.Duration
+}
+
+// Observer is an `interface` told about every `internal.Event` occurring in
+// the pipeline.
+//
+// NOTE: `Observe` is called concurrently by every stage of the pipeline and
+// must therefore be thread-safe. It should also return quickly as it blocks
+// the stage that called it.
+type Observer interface {
+	Observe(Event)
+}
+
+// Observable is an `interface` implemented by `internal.Pipe`s able to tell
+// `internal.Observer`s about the `*domain.Target`s going through them.
+type Observable interface {
+	SetObservers(...Observer)
+}
+
+// SetObservers makes `r` notify every `internal.Event` to `obs`, replacing the
+// previous `internal.Observer`s.
+//
+// NOTE: This function must be called before the `internal.Pipe` embedding `r`
+// is started.
+func (r *Reporter) SetObservers(obs ...Observer) {
+	r.observers = obs
+}
+
+// newEvent returns a new `internal.Event` of `kind` about `t` in `stage`.
+func newEvent(kind EventKind, stage string, t *domain.Target, reason string) Event {
+	return Event{
+		Kind:   kind,
+		Stage:  stage,
+		URL:    t.BaseURL,
+		Parent: t.Parent,
+		Depth:  t.Depth,
+		Reason: reason,
+		Time:   time.Now(),
+	}
+}
+
+// send sends `e` to every `internal.Observer` of `r`.
+func (r *Reporter) send(e Event) {
+	for _, o := range r.observers {
+		o.Observe(e)
+	}
+}
+
+// notify sends an `internal.Event` of `kind` about `t` in `stage` to every
+// `internal.Observer` of `r`.
+func (r *Reporter) notify(kind EventKind, stage string, t *domain.Target, reason string) {
+	if len(r.observers) == 0 {
+		return
+	}
+	r.send(newEvent(kind, stage, t, reason))
+}
+
+// NotifyEnter tells the `internal.Observer`s of `r` that `t` entered `stage`.
+func (r *Reporter) NotifyEnter(stage string, t *domain.Target) {
+	r.notify(EventEnter, stage, t, "")
+}
+
+// NotifyLeave tells the `internal.Observer`s of `r` that `t` left `stage`.
+func (r *Reporter) NotifyLeave(stage string, t *domain.Target) {
+	r.notify(EventLeave, stage, t, "")
+}
+
+// NotifyDiscard tells the `internal.Observer`s of `r` that `t` has been
+// discarded by `stage` because of `reason`.
+func (r *Reporter) NotifyDiscard(stage string, t *domain.Target, reason string) {
+	r.notify(EventDiscard, stage, t, reason)
+}
+
+// NotifyEmit tells the `internal.Observer`s of `r` that `t` has been created
+// by `stage`.
+func (r *Reporter) NotifyEmit(stage string, t *domain.Target) {
+	r.notify(EventEmit, stage, t, "")
+}
+
+// NotifyRequest tells the `internal.Observer`s of `r` that `stage` sent an HTTP
+// request for `t`.
+func (r *Reporter) NotifyRequest(stage string, t *domain.Target) {
+	r.notify(EventRequest, stage, t, "")
+}
+
+// NotifyFetch tells the `internal.Observer`s of `r` that `stage` received a
+// response with `status` and a body of `size` bytes for `t` after `d`. A
+// `status` of 0 means that no response has been received.
+func (r *Reporter) NotifyFetch(stage string, t *domain.Target, status, size int, d time.Duration) {
+	if len(r.observers) == 0 {
+		return
+	}
+
+	e := newEvent(EventFetch, stage, t, "")
+	e.Status, e.Bytes, e.Duration = status, size, d
+	r.send(e)
+}
@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+// mockObserver is a `struct` only used for test purposes. It implements the
+// `internal.Observer` interface.
+type mockObserver struct {
+	events []Event
+}
+
+// Observe implements the `internal.Observer` interface.
+func (mo *mockObserver) Observe(e Event) { mo.events = append(mo.events, e) }
+
+func TestEventKind_String(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mockKind    EventKind
+		expectedStr string
+	}{
+		{"enter", EventEnter, "enter"},
+		{"leave", EventLeave, "leave"},
+		{"discard", EventDiscard, "discard"},
+		{"emit", EventEmit, "emit"},
+		{"fetch", EventFetch, "fetch"},
+		{"request", EventRequest, "request"},
+		{"unknown", EventKind(42), "unknown"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedStr, tc.mockKind.String())
+		})
+	}
+}
+
+func TestReporter_Notify(t *testing.T) {
+	parent := domain.NewTarget("http://a.com")
+	child := domain.NewChildTarget("http://a.com/b", parent)
+
+	testCases := []struct {
+		name          string
+		mockNotify    func(*Reporter)
+		expectedEvent Event
+	}{
+		{
+			"enter",
+			func(r *Reporter) { r.NotifyEnter("stage", parent) },
+			Event{Kind: EventEnter, Stage: "stage", URL: "http://a.com"},
+		},
+		{
+			"leave",
+			func(r *Reporter) { r.NotifyLeave("stage", parent) },
+			Event{Kind: EventLeave, Stage: "stage", URL: "http://a.com"},
+		},
+		{
+			"discard",
+			func(r *Reporter) { r.NotifyDiscard("stage", child, ReasonOffHost) },
+			Event{Kind: EventDiscard, Stage: "stage", URL: "http://a.com/b", Parent: "http://a.com", Depth: 1, Reason: ReasonOffHost},
+		},
+		{
+			"emit",
+			func(r *Reporter) { r.NotifyEmit("stage", child) },
+			Event{Kind: EventEmit, Stage: "stage", URL: "http://a.com/b", Parent: "http://a.com", Depth: 1},
+		},
+		{
+			"request",
+			func(r *Reporter) { r.NotifyRequest("stage", parent) },
+			Event{Kind: EventRequest, Stage: "stage", URL: "http://a.com"},
+		},
+		{
+			"fetch",
+			func(r *Reporter) { r.NotifyFetch("stage", parent, 200, 42, time.Second) },
+			Event{Kind: EventFetch, Stage: "stage", URL: "http://a.com", Status: 200, Bytes: 42, Duration: time.Second},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mo1, mo2 := &mockObserver{}, &mockObserver{}
+			r := Reporter{}
+			r.SetObservers(mo1, mo2)
+
+			tc.mockNotify(&r)
+			for _, mo := range []*mockObserver{mo1, mo2} {
+				assert.Len(t, mo.events, 1)
+				assert.False(t, mo.events[0].Time.IsZero())
+				mo.events[0].Time = tc.expectedEvent.Time
+				assert.Equal(t, tc.expectedEvent, mo.events[0])
+			}
+		})
+	}
+}
+
+func TestReporter_Notify_noObserver(t *testing.T) {
+	r := Reporter{}
+	assert.NotPanics(t, func() { r.NotifyEnter("stage", domain.NewTarget("http://a.com")) })
+}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"sync"
 
 	"github.com/timtosi/mcrawler/internal/domain"
@@ -8,6 +9,50 @@ import (
 
 // Pipe is an `interface` used by `*crawler.Crawler` to build a crawling
 // pipeline.
+//
+// NOTE: Once `ctx` is cancelled, a `Pipe` should discard every
+// `*domain.Target` it receives instead of processing it.
 type Pipe interface {
-	Pipe(*sync.WaitGroup, <-chan *domain.Target, chan<- *domain.Target)
+	Pipe(context.Context, *sync.WaitGroup, <-chan *domain.Target, chan<- *domain.Target)
+}
+
+// Consumer is an `interface` implemented by `internal.Pipe`s never sending to
+// `out` the `*domain.Target`s they receive, e.g. `*extractor.Extractor` which
+// only sends the links found in them. A `*domain.Target` leaving such an
+// `internal.Pipe` leaves the pipeline.
+type Consumer interface {
+	ConsumesTargets()
+}
+
+// ForEach calls `fn` with every `*domain.Target` received from `in`. At most
+// `n` calls to `fn` run concurrently, so that `in` is not read while they are
+// all busy. When `n` is lower than 1, a new goroutine is started for every
+// `*domain.Target` instead.
+//
+// NOTE: This function will loop over a channel until `in` is closed. After that
+// it will return once every call to `fn` has returned.
+func ForEach(n int, in <-chan *domain.Target, fn func(*domain.Target)) {
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+
+	if n < 1 {
+		for t := range in {
+			wg.Add(1)
+			go func(tgt *domain.Target) {
+				defer wg.Done()
+				fn(tgt)
+			}(t)
+		}
+		return
+	}
+
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for t := range in {
+				fn(t)
+			}
+		}()
+	}
 }
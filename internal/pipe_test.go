@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+func TestForEach(t *testing.T) {
+	testCases := []struct {
+		name            string
+		mockConcurrency int
+		mockTargets     int
+		expectedMax     int32
+	}{
+		{"unbounded", 0, 20, 20},
+		{"sequential", 1, 20, 1},
+		{"bounded", 4, 20, 4},
+		{"moreWorkersThanTargets", 50, 20, 20},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var running, max, calls int32
+			inChan := make(chan *domain.Target)
+			release := make(chan struct{})
+			done := make(chan struct{})
+
+			go func() {
+				ForEach(tc.mockConcurrency, inChan, func(*domain.Target) {
+					n := atomic.AddInt32(&running, 1)
+					for m := atomic.LoadInt32(&max); n > m; m = atomic.LoadInt32(&max) {
+						if atomic.CompareAndSwapInt32(&max, m, n) {
+							break
+						}
+					}
+					<-release
+					atomic.AddInt32(&running, -1)
+					atomic.AddInt32(&calls, 1)
+				})
+				close(done)
+			}()
+
+			sent := sync.WaitGroup{}
+			sent.Add(1)
+			go func() {
+				defer sent.Done()
+				for i := 0; i < tc.mockTargets; i++ {
+					inChan <- domain.NewTarget("http://a.com")
+				}
+				close(inChan)
+			}()
+
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			sent.Wait()
+			<-done
+
+			assert.Equal(t, tc.expectedMax, max)
+			assert.Equal(t, int32(tc.mockTargets), calls)
+		})
+	}
+}
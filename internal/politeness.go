@@ -0,0 +1,290 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// busyDelay is how long `Politeness.TryWait` asks to wait before trying again
+// when the maximum number of requests in flight to a host is reached.
+const busyDelay = 50 * time.Millisecond
+
+// pacedKey is the key of the `context.Context` value marking the requests
+// already allowed by `Politeness.TryWait`.
+type pacedKey struct{}
+
+// withPaced returns a copy of `ctx` marking the requests made with it as
+// already allowed by `Politeness.TryWait`, so that `Worker.Fetch` does not wait
+// for them again.
+func withPaced(ctx context.Context) context.Context {
+	return context.WithValue(ctx, pacedKey{}, true)
+}
+
+// isPaced returns `true` if the requests made with `ctx` have already been
+// allowed by `Politeness.TryWait`.
+func isPaced(ctx context.Context) bool {
+	paced, _ := ctx.Value(pacedKey{}).(bool)
+	return paced
+}
+
+// HostPolicy is a `struct` describing how politely a host is crawled.
+//
+// `Delay` is the minimum time between the start of two requests, `MaxConns`
+// the maximum number of requests in flight and `Rate` the maximum number of
+// requests per second, allowing bursts of `Burst` requests. A zero value
+// disables the matching limit.
+type HostPolicy struct {
+	Delay    time.Duration
+	MaxConns int
+	Rate     float64
+	Burst    int
+}
+
+// hostState is a `struct` keeping track of the requests made to a host.
+type hostState struct {
+	policy HostPolicy
+	conns  chan struct{}
+	next   time.Time
+	tokens float64
+	last   time.Time
+}
+
+// newHostState returns a new `*internal.hostState` enforcing `p`.
+func newHostState(p HostPolicy) *hostState {
+	hs := &hostState{policy: p}
+	if p.MaxConns > 0 {
+		hs.conns = make(chan struct{}, p.MaxConns)
+	}
+	if hs.policy.Rate > 0 && hs.policy.Burst < 1 {
+		hs.policy.Burst = 1
+	}
+	hs.tokens = float64(hs.policy.Burst)
+	return hs
+}
+
+// reserve returns the time at which a new request can start according to
+// `hs.policy.Delay` and `hs.policy.Rate` and accounts for it.
+//
+// NOTE: This function is not thread-safe.
+func (hs *hostState) reserve(now time.Time) time.Time {
+	at := now
+	if hs.next.After(at) {
+		at = hs.next
+	}
+
+	if hs.policy.Rate > 0 {
+		if !hs.last.IsZero() {
+			hs.tokens += at.Sub(hs.last).Seconds() * hs.policy.Rate
+		}
+		if hs.tokens > float64(hs.policy.Burst) {
+			hs.tokens = float64(hs.policy.Burst)
+		}
+		if hs.tokens < 1 {
+			at = at.Add(time.Duration((1 - hs.tokens) / hs.policy.Rate * float64(time.Second)))
+			hs.tokens = 1
+		}
+		hs.tokens--
+		hs.last = at
+	}
+
+	hs.next = at.Add(hs.policy.Delay)
+	return at
+}
+
+// Politeness is a `struct` limiting the pace at which requests are sent to
+// each host according to a default `internal.HostPolicy` and per host ones.
+type Politeness struct {
+	defaults HostPolicy
+	policies map[string]HostPolicy
+	hosts    map[string]*hostState
+	mu       *sync.Mutex
+}
+
+// NewPoliteness returns a new `*internal.Politeness` applying `defaults` to
+// every host that can be configured through `opts` functions.
+func NewPoliteness(defaults HostPolicy, opts ...func(*Politeness)) *Politeness {
+	p := &Politeness{
+		defaults: defaults,
+		policies: make(map[string]HostPolicy),
+		hosts:    make(map[string]*hostState),
+		mu:       &sync.Mutex{},
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// WithHostPolicy makes a `*internal.Politeness` apply `hp` to `host` instead
+// of its default `internal.HostPolicy`.
+func WithHostPolicy(host string, hp HostPolicy) func(*Politeness) {
+	return func(p *Politeness) { p.policies[host] = hp }
+}
+
+// state returns the `*internal.hostState` of `host`, creating it if needed.
+//
+// NOTE: This function is not thread-safe.
+func (p *Politeness) state(host string) *hostState {
+	hs, ok := p.hosts[host]
+	if !ok {
+		hp, ok := p.policies[host]
+		if !ok {
+			hp = p.defaults
+		}
+		hs = newHostState(hp)
+		p.hosts[host] = hs
+	}
+	return hs
+}
+
+// SetMinDelay makes `p` wait at least `d` between two requests to `host`, e.g.
+// when asked to by the `Crawl-delay` of a robots.txt file. It has no effect if
+// the `internal.HostPolicy` of `host` already has a longer `Delay`.
+//
+// NOTE: This function is thread-safe.
+func (p *Politeness) SetMinDelay(host string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	hp, ok := p.policies[host]
+	if !ok {
+		hp = p.defaults
+	}
+	if d > hp.Delay {
+		hp.Delay = d
+		p.policies[host] = hp
+	}
+	if hs, ok := p.hosts[host]; ok && d > hs.policy.Delay {
+		hs.policy.Delay = d
+	}
+}
+
+// Wait blocks until a request can be sent to `host`. It returns a function
+// that must be called once the request is over, or an `error` if `ctx` is
+// cancelled while waiting.
+//
+// NOTE: This function is thread-safe.
+func (p *Politeness) Wait(ctx context.Context, host string) (func(), error) {
+	p.mu.Lock()
+	hs := p.state(host)
+	p.mu.Unlock()
+
+	release := func() {}
+	if hs.conns != nil {
+		select {
+		case hs.conns <- struct{}{}:
+			release = func() { <-hs.conns }
+		case <-ctx.Done():
+			return nil, fmt.Errorf("Wait: %v", ctx.Err())
+		}
+	}
+
+	if err := p.pace(ctx, host); err != nil {
+		release()
+		return nil, fmt.Errorf("Wait: %v", err)
+	}
+	return release, nil
+}
+
+// pace blocks until the `Delay` and `Rate` of the `internal.HostPolicy` of
+// `host` allow a new request, regardless of its `MaxConns`, e.g. for the
+// redirects of a request already in flight. It returns an `error` if `ctx` is
+// cancelled while waiting.
+//
+// NOTE: This function is thread-safe.
+func (p *Politeness) pace(ctx context.Context, host string) error {
+	p.mu.Lock()
+	wait := time.Until(p.state(host).reserve(time.Now()))
+	p.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TryWait is the non-blocking counterpart of `Politeness.Wait`. If a request
+// can be sent to `host` right away, it returns a function that must be called
+// once the request is over. Otherwise it returns how long to wait before
+// trying again, and nothing is accounted for.
+//
+// NOTE: This function is thread-safe.
+func (p *Politeness) TryWait(host string) (func(), time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	hs := p.state(host)
+	release := func() {}
+	if hs.conns != nil {
+		select {
+		case hs.conns <- struct{}{}:
+			release = func() { <-hs.conns }
+		default:
+			return nil, busyDelay
+		}
+	}
+
+	now := time.Now()
+	peek := *hs
+	if at := peek.reserve(now); at.After(now) {
+		release()
+		return nil, at.Sub(now)
+	}
+	hs.reserve(now)
+	return release, 0
+}
+
+// ParseHostPolicy parses `s`, a comma separated list of `key=value` pairs
+// such as `host=example.com,delay=1s,max-conns=2,rate=0.5,burst=2`. It
+// returns the host and its `internal.HostPolicy`, or an `error` if `s` is
+// malformed.
+//
+// NOTE: The `host` key is mandatory, other keys are optional.
+func ParseHostPolicy(s string) (string, HostPolicy, error) {
+	var host string
+	var hp HostPolicy
+
+	for _, field := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) != 2 {
+			return "", HostPolicy{}, fmt.Errorf("ParseHostPolicy: %q is not a key=value pair", field)
+		}
+
+		var err error
+		switch kv[0] {
+		case "host":
+			host = kv[1]
+		case "delay":
+			hp.Delay, err = time.ParseDuration(kv[1])
+		case "max-conns":
+			hp.MaxConns, err = strconv.Atoi(kv[1])
+		case "rate":
+			hp.Rate, err = strconv.ParseFloat(kv[1], 64)
+		case "burst":
+			hp.Burst, err = strconv.Atoi(kv[1])
+		default:
+			err = fmt.Errorf("unknown key %q", kv[0])
+		}
+		if err != nil {
+			return "", HostPolicy{}, fmt.Errorf("ParseHostPolicy: %v", err)
+		}
+	}
+
+	if len(host) == 0 {
+		return "", HostPolicy{}, fmt.Errorf("ParseHostPolicy: no host found in %q", s)
+	}
+	return host, hp, nil
+}
@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostState_reserve(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name        string
+		mockPolicy  HostPolicy
+		expectedAts []time.Duration
+	}{
+		{"noLimit", HostPolicy{}, []time.Duration{0, 0, 0}},
+		{"delay", HostPolicy{Delay: time.Second}, []time.Duration{0, time.Second, 2 * time.Second}},
+		{"rate", HostPolicy{Rate: 2}, []time.Duration{0, 500 * time.Millisecond, time.Second}},
+		{"rateBurst", HostPolicy{Rate: 2, Burst: 2}, []time.Duration{0, 0, 500 * time.Millisecond}},
+		{
+			"delayAndRate",
+			HostPolicy{Delay: time.Second, Rate: 2, Burst: 2},
+			[]time.Duration{0, time.Second, 2 * time.Second},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hs := newHostState(tc.mockPolicy)
+			for _, expected := range tc.expectedAts {
+				assert.Equal(t, now.Add(expected), hs.reserve(now))
+			}
+		})
+	}
+}
+
+func TestPoliteness_Wait(t *testing.T) {
+	p := NewPoliteness(
+		HostPolicy{Delay: 100 * time.Millisecond},
+		WithHostPolicy("fast.com", HostPolicy{}),
+	)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		release, err := p.Wait(context.Background(), "fast.com")
+		assert.Nil(t, err)
+		release()
+	}
+	assert.True(t, time.Since(start) < 50*time.Millisecond)
+
+	start = time.Now()
+	for i := 0; i < 3; i++ {
+		release, err := p.Wait(context.Background(), "slow.com")
+		assert.Nil(t, err)
+		release()
+	}
+	assert.True(t, time.Since(start) >= 200*time.Millisecond)
+}
+
+func TestPoliteness_Wait_maxConns(t *testing.T) {
+	p := NewPoliteness(HostPolicy{MaxConns: 1})
+
+	release, err := p.Wait(context.Background(), "a.com")
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = p.Wait(ctx, "a.com")
+	assert.NotNil(t, err)
+
+	other, err := p.Wait(context.Background(), "b.com")
+	assert.Nil(t, err)
+	other()
+
+	release()
+	release, err = p.Wait(context.Background(), "a.com")
+	assert.Nil(t, err)
+	release()
+}
+
+func TestPoliteness_Wait_cancel(t *testing.T) {
+	p := NewPoliteness(HostPolicy{Delay: time.Hour})
+
+	release, err := p.Wait(context.Background(), "a.com")
+	assert.Nil(t, err)
+	release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = p.Wait(ctx, "a.com")
+	assert.NotNil(t, err)
+}
+
+func TestPoliteness_TryWait(t *testing.T) {
+	p := NewPoliteness(HostPolicy{Delay: time.Hour}, WithHostPolicy("b.com", HostPolicy{MaxConns: 1}))
+
+	release, wait := p.TryWait("a.com")
+	assert.Equal(t, time.Duration(0), wait)
+	release()
+	_, wait = p.TryWait("a.com")
+	assert.True(t, wait > 59*time.Minute)
+	_, wait = p.TryWait("a.com")
+	assert.True(t, wait > 59*time.Minute && wait <= time.Hour)
+
+	release, wait = p.TryWait("b.com")
+	assert.Equal(t, time.Duration(0), wait)
+	_, wait = p.TryWait("b.com")
+	assert.Equal(t, busyDelay, wait)
+	release()
+	release, wait = p.TryWait("b.com")
+	assert.Equal(t, time.Duration(0), wait)
+	release()
+}
+
+func TestParseHostPolicy(t *testing.T) {
+	testCases := []struct {
+		name           string
+		mockStr        string
+		expectedHost   string
+		expectedPolicy HostPolicy
+		expectedErr    bool
+	}{
+		{"hostOnly", "host=a.com", "a.com", HostPolicy{}, false},
+		{
+			"allKeys",
+			"host=a.com:8080, delay=1s,max-conns=2,rate=0.5,burst=3",
+			"a.com:8080",
+			HostPolicy{Delay: time.Second, MaxConns: 2, Rate: 0.5, Burst: 3},
+			false,
+		},
+		{"noHost", "delay=1s", "", HostPolicy{}, true},
+		{"notKeyValue", "host=a.com,delay", "", HostPolicy{}, true},
+		{"unknownKey", "host=a.com,speed=1", "", HostPolicy{}, true},
+		{"badValue", "host=a.com,delay=1", "", HostPolicy{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			host, hp, err := ParseHostPolicy(tc.mockStr)
+			assert.Equal(t, tc.expectedErr, err != nil)
+			assert.Equal(t, tc.expectedHost, host)
+			assert.Equal(t, tc.expectedPolicy, hp)
+		})
+	}
+}
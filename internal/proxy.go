@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// proxies is a `struct` choosing the proxy through which a `*internal.Worker`
+// sends each request, if any.
+type proxies struct {
+	fallback func(*http.Request) (*url.URL, error)
+	hosts    map[string]*url.URL
+}
+
+// newProxies returns a new `*internal.proxies` using the proxies given by the
+// `HTTP_PROXY`, `HTTPS_PROXY` and `NO_PROXY` environment variables.
+func newProxies() *proxies {
+	return &proxies{
+		fallback: http.ProxyFromEnvironment,
+		hosts:    make(map[string]*url.URL),
+	}
+}
+
+// proxy implements the `Proxy` function of `http.Transport`. It returns the
+// proxy given for the host of `req`, looked up first with its port and then
+// without it, or the one of `p.fallback`. A `nil` proxy means that `req` is
+// sent directly.
+func (p *proxies) proxy(req *http.Request) (*url.URL, error) {
+	if u, ok := p.hosts[hostKey(req.URL.Host)]; ok {
+		return u, nil
+	}
+	if u, ok := p.hosts[hostKey(req.URL.Hostname())]; ok {
+		return u, nil
+	}
+	return p.fallback(req)
+}
@@ -0,0 +1,192 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+// mockProxy is an helper function only used for test purposes. It returns a
+// `*httptest.Server` standing in for a HTTP proxy, answering itself every
+// request it receives with the URL requested, and the URL to reach it.
+func mockProxy(t *testing.T) (*httptest.Server, *url.URL) {
+	ms := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "http proxy: %s", r.URL)
+		}),
+	)
+	u, err := url.Parse(ms.URL)
+	if err != nil {
+		t.Fatalf("mockProxy: %v", err)
+	}
+	return ms, u
+}
+
+// mockSOCKS5 is an helper function only used for test purposes. It returns a
+// `net.Listener` standing in for a SOCKS5 proxy without authentication,
+// answering itself every HTTP request it receives with the address requested,
+// and the URL to reach it.
+func mockSOCKS5(t *testing.T) (net.Listener, *url.URL) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("mockSOCKS5: %v", err)
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSOCKS5(conn)
+		}
+	}()
+	return l, &url.URL{Scheme: "socks5", Host: l.Addr().String()}
+}
+
+// serveSOCKS5 is an helper function only used for test purposes. It performs
+// the SOCKS5 handshake of `conn` and answers the HTTP request sent through it.
+func serveSOCKS5(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	// Greeting: version, methods.
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(r, make([]byte, head[1])); err != nil {
+		return
+	}
+	conn.Write([]byte{5, 0})
+
+	// Request: version, command, reserved, address type, address, port.
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(r, req); err != nil {
+		return
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		ip := make([]byte, 4)
+		io.ReadFull(r, ip)
+		host = net.IP(ip).String()
+	case 3:
+		n, _ := r.ReadByte()
+		name := make([]byte, n)
+		io.ReadFull(r, name)
+		host = string(name)
+	default:
+		return
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(r, port); err != nil {
+		return
+	}
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	httpReq, err := http.ReadRequest(r)
+	if err != nil {
+		return
+	}
+	body := fmt.Sprintf("socks5 proxy: %s %s", net.JoinHostPort(host, fmt.Sprint(binary.BigEndian.Uint16(port))), httpReq.URL.Path)
+	fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s", len(body), body)
+}
+
+func TestProxies_proxy(t *testing.T) {
+	envProxy := &url.URL{Scheme: "http", Host: "env:3128"}
+	hostProxy := &url.URL{Scheme: "socks5", Host: "gateway:1080"}
+
+	p := newProxies()
+	p.fallback = http.ProxyURL(envProxy)
+	p.hosts["a.com"] = hostProxy
+	p.hosts["b.com:8080"] = nil
+
+	for link, expected := range map[string]*url.URL{
+		"http://a.com/page":      hostProxy,
+		"http://A.com:8080/page": hostProxy,
+		"http://b.com:8080/":     nil,
+		"http://b.com/":          envProxy,
+		"http://c.com/":          envProxy,
+	} {
+		req, err := http.NewRequest(http.MethodGet, link, nil)
+		if err != nil {
+			t.Fatalf("TestProxies_proxy: %v", err)
+		}
+		u, err := p.proxy(req)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, u, link)
+	}
+}
+
+func TestWorker_WithProxy(t *testing.T) {
+	hp, hpURL := mockProxy(t)
+	defer hp.Close()
+	sp, spURL := mockSOCKS5(t)
+	defer sp.Close()
+	direct := mockServer()
+	defer direct.Close()
+	directURL, err := url.Parse(direct.URL)
+	if err != nil {
+		t.Fatalf("TestWorker_WithProxy: %v", err)
+	}
+
+	testCases := []struct {
+		name            string
+		mockOpts        []func(*Worker)
+		mockURL         string
+		expectedContent string
+	}{
+		{
+			"httpProxy",
+			[]func(*Worker){WithProxy(hpURL)},
+			"http://corp.invalid/page",
+			"http proxy: http://corp.invalid/page",
+		},
+		{
+			"socks5Proxy",
+			[]func(*Worker){WithProxy(spURL)},
+			"http://corp.invalid/page",
+			"socks5 proxy: corp.invalid:80 /page",
+		},
+		{
+			"hostProxy",
+			[]func(*Worker){WithProxy(hpURL), WithHostProxy("corp.invalid", spURL)},
+			"http://corp.invalid/page",
+			"socks5 proxy: corp.invalid:80 /page",
+		},
+		{
+			"hostDirect",
+			[]func(*Worker){WithProxy(hpURL), WithHostProxy(directURL.Host, nil)},
+			direct.URL + "/good",
+			"correctly retrieved",
+		},
+		{
+			"noProxy",
+			[]func(*Worker){WithProxy(nil)},
+			direct.URL + "/good",
+			"correctly retrieved",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := NewWorker(tc.mockOpts...)
+			tgt := domain.NewTarget(tc.mockURL)
+
+			assert.Nil(t, w.Fetch(context.Background(), tgt))
+			assert.Equal(t, tc.expectedContent, strings.TrimSpace(string(tgt.Content)))
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+var (
+	// ErrRedirectLoop is the `error` used when a web page redirects to a URL
+	// already visited while following its redirects.
+	ErrRedirectLoop = errors.New("redirect loop")
+	// ErrTooManyRedirects is the `error` used when a web page redirects more
+	// times than allowed by `internal.WithMaxRedirects`.
+	ErrTooManyRedirects = errors.New("too many redirects")
+)
+
+// RedirectChecker is an `interface` implemented by `internal.Pipe`s whose
+// checks also apply to every URL a `*domain.Target` is redirected to by the
+// `*internal.Worker`, e.g. `*internal.Follower` and `*internal.Archiver`.
+type RedirectChecker interface {
+	// CheckRedirect returns the reason why a `*domain.Target` redirected to
+	// `link` should be discarded, or an empty string if it should not.
+	CheckRedirect(link string) string
+}
+
+// RedirectError is an `error` returned by `Worker.Fetch` when a redirect to
+// `URL` is not followed. `Reason` is the reason given by the
+// `internal.RedirectChecker` that rejected it, or `internal.ReasonBadRedirect`
+// along with `Err` for redirect loops and too many redirects.
+type RedirectError struct {
+	URL    string
+	Reason string
+	Err    error
+	Chain  []domain.Redirect
+}
+
+// Error implements the `error` interface.
+func (e *RedirectError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("redirect to %s: %v", e.URL, e.Err)
+	}
+	return fmt.Sprintf("redirect to %s: %s", e.URL, e.Reason)
+}
+
+// minUncheckedRedirects is the number of redirects followed at least by the
+// requests made with `internal.WithUncheckedRedirects`, as required for
+// robots.txt files by RFC 9309.
+const minUncheckedRedirects = 5
+
+// uncheckedKey is the key of the `context.Context` value marking the requests
+// whose redirects are not checked by the `internal.RedirectChecker`s.
+type uncheckedKey struct{}
+
+// WithUncheckedRedirects returns a copy of `ctx` marking the requests made
+// with it as not part of the crawl, e.g. robots.txt files or sitemaps, so that
+// their redirects are not checked by the `internal.RedirectChecker`s of the
+// `*internal.Worker` and at least `internal.minUncheckedRedirects` of them are
+// followed.
+//
+// NOTE: Requests made with the `http.Client` of an `*internal.Worker` outside
+// of the crawling pipeline should use it, otherwise the URLs they are
+// redirected to are seen as crawled.
+func WithUncheckedRedirects(ctx context.Context) context.Context {
+	return context.WithValue(ctx, uncheckedKey{}, true)
+}
+
+// claimedKey is the key of the `context.Context` value holding the redirects
+// followed by the previous attempt to fetch a web page, whose URLs have
+// already been checked by the `internal.RedirectChecker`s.
+type claimedKey struct{}
+
+// withClaimed returns a copy of `ctx` holding `chain`, the redirects followed by
+// the previous attempt to fetch a web page.
+func withClaimed(ctx context.Context, chain []domain.Redirect) context.Context {
+	return context.WithValue(ctx, claimedKey{}, chain)
+}
+
+// isClaimed returns `true` if `link` has been redirected to by the previous
+// attempt to fetch the web page requested with `ctx`.
+func isClaimed(ctx context.Context, link string) bool {
+	chain, _ := ctx.Value(claimedKey{}).([]domain.Redirect)
+	for _, r := range chain {
+		if r.Location == link {
+			return true
+		}
+	}
+	return false
+}
+
+// redirectChain returns the chain of redirects followed to send `req`, where
+// `via` are the requests already sent, oldest first.
+func redirectChain(req *http.Request, via []*http.Request) []domain.Redirect {
+	chain := make([]domain.Redirect, len(via))
+	for i, r := range via {
+		next := req
+		if i+1 < len(via) {
+			next = via[i+1]
+		}
+		chain[i] = domain.Redirect{
+			URL:        r.URL.String(),
+			StatusCode: next.Response.StatusCode,
+			Location:   next.URL.String(),
+		}
+	}
+	return chain
+}
+
+// responseChain returns the chain of redirects followed to receive `resp`.
+func responseChain(resp *http.Response) []domain.Redirect {
+	var via []*http.Request
+	for r := resp.Request; r.Response != nil; r = r.Response.Request {
+		via = append([]*http.Request{r.Response.Request}, via...)
+	}
+	if len(via) == 0 {
+		return nil
+	}
+	return redirectChain(resp.Request, via)
+}
+
+// checkRedirect implements the `CheckRedirect` function of `http.Client`. It
+// stops following the redirects of a web page once they loop, once there are
+// more than `w.maxRedirects` of them or when one of `w.redirectCheckers`
+// rejects the URL of `req`.
+//
+// NOTE: Requests made with `internal.WithUncheckedRedirects` are not checked by
+// `w.redirectCheckers`.
+//
+// NOTE: Every redirect followed waits for the `Delay` and `Rate` of the
+// `internal.HostPolicy` of its host in `w.politeness`, if any.
+//
+// NOTE: URLs already redirected to by a previous attempt to fetch the same web
+// page are not checked again by `w.redirectCheckers`, since they would be seen
+// as already crawled by the `*internal.Archiver` and the retry would be lost.
+func (w *Worker) checkRedirect(req *http.Request, via []*http.Request) error {
+	link := req.URL.String()
+	e := &RedirectError{URL: link, Reason: ReasonBadRedirect, Chain: redirectChain(req, via)}
+
+	for _, r := range via {
+		if r.URL.String() == link {
+			e.Err = ErrRedirectLoop
+			return e
+		}
+	}
+	unchecked, _ := req.Context().Value(uncheckedKey{}).(bool)
+	max := w.maxRedirects
+	if unchecked && max < minUncheckedRedirects {
+		max = minUncheckedRedirects
+	}
+	if len(via) > max {
+		e.Err = ErrTooManyRedirects
+		return e
+	}
+
+	if !unchecked && !isClaimed(req.Context(), link) {
+		for _, rc := range w.redirectCheckers {
+			if reason := rc.CheckRedirect(link); len(reason) != 0 {
+				e.Reason = reason
+				return e
+			}
+		}
+	}
+
+	if w.politeness != nil {
+		return w.politeness.pace(req.Context(), req.URL.Host)
+	}
+	return nil
+}
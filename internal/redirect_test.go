@@ -0,0 +1,32 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirectError_Error(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mockErr     *RedirectError
+		expectedStr string
+	}{
+		{
+			"reason",
+			&RedirectError{URL: "http://a.com", Reason: ReasonOffHost},
+			"redirect to http://a.com: off-host",
+		},
+		{
+			"err",
+			&RedirectError{URL: "http://a.com", Reason: ReasonBadRedirect, Err: ErrRedirectLoop},
+			"redirect to http://a.com: redirect loop",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedStr, tc.mockErr.Error())
+		})
+	}
+}
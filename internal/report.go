@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// CrawlError is a `struct` representing a failure that occurred while a
+// `*domain.Target` located at `URL` was going through the `Stage` of the
+// pipeline.
+type CrawlError struct {
+	URL   string
+	Stage string
+	Err   error
+	Time  time.Time
+}
+
+// Error implements the `error` interface.
+func (e *CrawlError) Error() string {
+	return fmt.Sprintf("%s: %s: %v", e.Stage, e.URL, e.Err)
+}
+
+// ErrorSink is an `interface` collecting the `*internal.CrawlError`s reported
+// by the `internal.Pipe`s of a pipeline.
+type ErrorSink interface {
+	Report(*CrawlError)
+}
+
+// ErrorReporter is an `interface` implemented by `internal.Pipe`s able to
+// report their failures to an `internal.ErrorSink`.
+type ErrorReporter interface {
+	SetErrorSink(ErrorSink)
+}
+
+// Reporter is a `struct` meant to be embedded in `internal.Pipe`s in order to
+// implement `internal.ErrorReporter` and `internal.Observable`.
+type Reporter struct {
+	sink      ErrorSink
+	observers []Observer
+}
+
+// SetErrorSink makes `r` report errors to `s`.
+//
+// NOTE: This function must be called before the `internal.Pipe` embedding `r`
+// is started.
+func (r *Reporter) SetErrorSink(s ErrorSink) {
+	r.sink = s
+}
+
+// ReportError reports that `err` occurred while the page located at `url` was
+// going through `stage`. The resulting `*internal.CrawlError` is logged if no
+// `internal.ErrorSink` has been set.
+func (r *Reporter) ReportError(stage, url string, err error) {
+	ce := &CrawlError{URL: url, Stage: stage, Err: err, Time: time.Now()}
+	if r.sink == nil {
+		log.Print(ce)
+		return
+	}
+	r.sink.Report(ce)
+}
@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockSink is a `struct` only used for test purposes. It implements the
+// `internal.ErrorSink` interface.
+type mockSink struct {
+	errs []*CrawlError
+}
+
+// Report implements the `internal.ErrorSink` interface.
+func (ms *mockSink) Report(e *CrawlError) { ms.errs = append(ms.errs, e) }
+
+func TestCrawlError_Error(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mockErr     *CrawlError
+		expectedStr string
+	}{
+		{
+			"worker",
+			&CrawlError{URL: "http://a.com", Stage: "worker", Err: errors.New("EOF")},
+			"worker: http://a.com: EOF",
+		},
+		{
+			"emptyURL",
+			&CrawlError{Stage: "extractor", Err: errors.New("bad link")},
+			"extractor: : bad link",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedStr, tc.mockErr.Error())
+		})
+	}
+}
+
+func TestReporter_ReportError(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mockSink    *mockSink
+		expectedLen int
+	}{
+		{"noSink", nil, 0},
+		{"withSink", &mockSink{}, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := Reporter{}
+			if tc.mockSink != nil {
+				r.SetErrorSink(tc.mockSink)
+			}
+
+			mockErr := errors.New("EOF")
+			r.ReportError("worker", "http://a.com", mockErr)
+
+			if tc.mockSink != nil {
+				assert.Len(t, tc.mockSink.errs, tc.expectedLen)
+				assert.Equal(t, "worker", tc.mockSink.errs[0].Stage)
+				assert.Equal(t, "http://a.com", tc.mockSink.errs[0].URL)
+				assert.Equal(t, mockErr, tc.mockSink.errs[0].Err)
+				assert.False(t, tc.mockSink.errs[0].Time.IsZero())
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+// RetryPolicy is a `struct` representing how a `*internal.Worker` fetches
+// again a web page after a network error, a `429 Too Many Requests` or a `5xx`
+// response.
+//
+// A web page is fetched at most `MaxAttempts` times. Before the n-th retry,
+// the `*internal.Worker` waits `BaseDelay * 2^(n-1)`, capped at `MaxDelay`,
+// with a random jitter of up to half of it. When the response has a
+// `Retry-After` header, its value is used instead, capped at `MaxDelay` as
+// well.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// delay returns the time to wait before fetching again a web page that has
+// already been fetched `attempts` times and answered with `header`.
+func (p RetryPolicy) delay(attempts int, header http.Header) time.Duration {
+	if d, ok := retryAfter(header); ok {
+		if p.MaxDelay > 0 && d > p.MaxDelay {
+			return p.MaxDelay
+		}
+		return d
+	}
+
+	d := p.BaseDelay
+	for i := 1; i < attempts && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryAfter returns the delay given by the `Retry-After` header of `header`,
+// either as a number of seconds or as a date, or `false` if there is none.
+func retryAfter(header http.Header) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if len(value) == 0 {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// RetryError is an `error` reported by a `*internal.Worker` when a web page
+// could not be fetched after `Attempts` attempts. `Err` is the `error`
+// returned by the last one.
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+// Error implements the `error` interface.
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("gave up after %d attempts: %v", e.Attempts, e.Err)
+}
+
+// Unwrap returns the `error` returned by the last attempt.
+func (e *RetryError) Unwrap() error { return e.Err }
+
+// retryItem is a `struct` representing a `*domain.Target` waiting to be
+// fetched again `at` a given time.
+type retryItem struct {
+	t  *domain.Target
+	at time.Time
+}
+
+// retryQueue is a `struct` merging the `*domain.Target`s received by a
+// `*internal.Worker` with the ones waiting to be fetched again, so that no
+// goroutine is blocked while they wait.
+type retryQueue struct {
+	waiting []retryItem
+	pending int
+	wake    chan struct{}
+	mu      *sync.Mutex
+}
+
+// newRetryQueue returns a new `*internal.retryQueue`.
+func newRetryQueue() *retryQueue {
+	return &retryQueue{
+		wake: make(chan struct{}, 1),
+		mu:   &sync.Mutex{},
+	}
+}
+
+// signal wakes up `q.run` up.
+func (q *retryQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// retry makes `t` sent again by `q.run` after `d`.
+//
+// NOTE: This function is thread-safe.
+func (q *retryQueue) retry(t *domain.Target, d time.Duration) {
+	q.mu.Lock()
+	q.waiting = append(q.waiting, retryItem{t, time.Now().Add(d)})
+	q.mu.Unlock()
+	q.signal()
+}
+
+// done tells `q` that a `*domain.Target` it sent will not be fetched again.
+//
+// NOTE: This function is thread-safe.
+func (q *retryQueue) done() {
+	q.mu.Lock()
+	q.pending--
+	q.mu.Unlock()
+	q.signal()
+}
+
+// next returns the `*domain.Target` waiting in `q` that should be sent first
+// if it is due or if `flush` is `true`. Otherwise it returns how long to wait
+// until it is due, or `true` if `q` is empty and `closed` is `true`.
+func (q *retryQueue) next(flush, closed bool) (*domain.Target, time.Duration, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.waiting) == 0 {
+		return nil, 0, closed && q.pending == 0
+	}
+
+	first := 0
+	for i, item := range q.waiting {
+		if item.at.Before(q.waiting[first].at) {
+			first = i
+		}
+	}
+	wait := time.Until(q.waiting[first].at)
+	if !flush && wait > 0 {
+		return nil, wait, false
+	}
+
+	t := q.waiting[first].t
+	q.waiting = append(q.waiting[:first], q.waiting[first+1:]...)
+	return t, 0, false
+}
+
+// run sends to `out` every `*domain.Target` received from `in` along with the
+// ones given to `q.retry` once they are due. Once `ctx` is cancelled, they are
+// all sent without waiting.
+//
+// NOTE: This function will loop until `in` is closed and every
+// `*domain.Target` sent has been given to `q.done`. After that it will close
+// `out`.
+func (q *retryQueue) run(ctx context.Context, in <-chan *domain.Target, out chan<- *domain.Target) {
+	defer close(out)
+
+	cancelled := ctx.Done()
+	for {
+		t, wait, finished := q.next(ctx.Err() != nil, in == nil)
+		if t != nil {
+			out <- t
+			continue
+		} else if finished {
+			return
+		}
+
+		var timer *time.Timer
+		var due <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			due = timer.C
+		}
+
+		select {
+		case t, ok := <-in:
+			if !ok {
+				in = nil
+				break
+			}
+			q.mu.Lock()
+			q.pending++
+			q.mu.Unlock()
+			out <- t
+		case <-q.wake:
+		case <-due:
+		case <-cancelled:
+			cancelled = nil
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy_delay(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mockPolicy  RetryPolicy
+		mockAttempt int
+		mockHeader  http.Header
+		expectedMin time.Duration
+		expectedMax time.Duration
+	}{
+		{"first", RetryPolicy{BaseDelay: time.Second}, 1, nil, 500 * time.Millisecond, time.Second},
+		{"third", RetryPolicy{BaseDelay: time.Second}, 3, nil, 2 * time.Second, 4 * time.Second},
+		{"capped", RetryPolicy{BaseDelay: time.Second, MaxDelay: 3 * time.Second}, 10, nil, 1500 * time.Millisecond, 3 * time.Second},
+		{"zero", RetryPolicy{}, 2, nil, 0, 0},
+		{"retryAfter", RetryPolicy{BaseDelay: time.Second}, 1, http.Header{"Retry-After": {"5"}}, 5 * time.Second, 5 * time.Second},
+		{"retryAfterCapped", RetryPolicy{MaxDelay: 2 * time.Second}, 1, http.Header{"Retry-After": {"120"}}, 2 * time.Second, 2 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := tc.mockPolicy.delay(tc.mockAttempt, tc.mockHeader)
+			assert.True(t, d >= tc.expectedMin && d <= tc.expectedMax, d)
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mockValue   string
+		expectedMin time.Duration
+		expectedMax time.Duration
+		expectedOk  bool
+	}{
+		{"none", "", 0, 0, false},
+		{"seconds", "3", 3 * time.Second, 3 * time.Second, true},
+		{"negative", "-3", 0, 0, false},
+		{"date", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), 58 * time.Second, time.Minute, true},
+		{"pastDate", "Thu, 14 Mar 2019 15:09:26 GMT", 0, 0, true},
+		{"malformed", "soon", 0, 0, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := http.Header{}
+			if len(tc.mockValue) != 0 {
+				h.Set("Retry-After", tc.mockValue)
+			}
+
+			d, ok := retryAfter(h)
+			assert.Equal(t, tc.expectedOk, ok)
+			assert.True(t, d >= tc.expectedMin && d <= tc.expectedMax, d)
+		})
+	}
+}
+
+func TestRetryError(t *testing.T) {
+	e := &RetryError{Attempts: 3, Err: &StatusError{Code: http.StatusServiceUnavailable}}
+
+	assert.Equal(t, "gave up after 3 attempts: unexpected status 503 Service Unavailable", e.Error())
+
+	var se *StatusError
+	assert.True(t, errors.As(e, &se))
+	assert.Equal(t, http.StatusServiceUnavailable, se.Code)
+}
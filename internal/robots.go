@@ -0,0 +1,273 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/timtosi/mcrawler/internal/domain"
+	"github.com/timtosi/mcrawler/internal/robots"
+)
+
+// robotsStage is the name used by `*internal.Robots` to report errors and
+// notify events.
+const robotsStage = "robots"
+
+const (
+	// robotsTTL is how long a robots.txt file is cached, as recommended by
+	// RFC 9309.
+	robotsTTL = 24 * time.Hour
+	// unreachableTTL is how long a host is considered as fully disallowed
+	// after its robots.txt file could not be reached.
+	unreachableTTL = time.Minute
+	// maxRobotsWaiters is the maximum number of `*domain.Target`s waiting at
+	// the same time for a robots.txt file that is not cached yet.
+	maxRobotsWaiters = 64
+)
+
+// robotsEntry is a `struct` representing a cached robots.txt file. `rules`
+// and `expires` can only be read once `ready` is closed.
+type robotsEntry struct {
+	rules   *robots.Rules
+	expires time.Time
+	ready   chan struct{}
+}
+
+// Robots is a `struct` discarding pages disallowed by the robots.txt file of
+// their host.
+type Robots struct {
+	Reporter
+
+	worker    *Worker
+	userAgent string
+	cache     map[string]*robotsEntry
+	mu        *sync.Mutex
+}
+
+// NewRobots returns a new `*internal.Robots` fetching robots.txt files with
+// the HTTP client of `w` that can be configured through `opts` functions. By
+// default, rules for the `mcrawler` user-agent are followed.
+//
+// NOTE: The `Crawl-delay` of a robots.txt file is applied to the
+// `*internal.Politeness` of `w`, if any.
+func NewRobots(w *Worker, opts ...func(*Robots)) *Robots {
+	r := &Robots{
+		worker:    w,
+		userAgent: "mcrawler",
+		cache:     make(map[string]*robotsEntry),
+		mu:        &sync.Mutex{},
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// WithRobotsAgent makes a `*internal.Robots` follow the rules given to the
+// `token` user-agent.
+func WithRobotsAgent(token string) func(*Robots) {
+	return func(r *Robots) { r.userAgent = token }
+}
+
+// fetch fetches and parses the robots.txt file located at the root of `site`.
+// It returns the resulting `*robots.Rules` and how long they can be cached.
+//
+// NOTE: As defined by RFC 9309, every path is allowed when the file is
+// unavailable (4xx) and disallowed when it is unreachable (5xx or network
+// error).
+//
+// NOTE: Redirects are followed regardless of the `internal.RedirectChecker`s
+// of `r.worker`, e.g. to another host, so that the file is neither rejected as
+// off-host or already seen nor recorded as seen.
+func (r *Robots) fetch(ctx context.Context, site *url.URL) (*robots.Rules, time.Duration) {
+	robotsURL := site.Scheme + "://" + site.Host + "/robots.txt"
+	req, err := http.NewRequest(http.MethodGet, robotsURL, nil)
+	if err != nil {
+		r.ReportError(robotsStage, robotsURL, err)
+		return robots.DisallowAll(), unreachableTTL
+	}
+
+	if r.worker.politeness != nil {
+		release, err := r.worker.politeness.Wait(ctx, site.Host)
+		if err != nil {
+			return robots.DisallowAll(), unreachableTTL
+		}
+		defer release()
+	}
+
+	resp, err := r.worker.Do(req.WithContext(WithUncheckedRedirects(ctx)))
+	if err != nil {
+		if ctx.Err() == nil {
+			r.ReportError(robotsStage, robotsURL, err)
+		}
+		return robots.DisallowAll(), unreachableTTL
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		rules, err := robots.Parse(resp.Body, r.userAgent)
+		if err != nil {
+			r.ReportError(robotsStage, robotsURL, err)
+			return robots.DisallowAll(), unreachableTTL
+		}
+		if rules.CrawlDelay > 0 && r.worker.politeness != nil {
+			r.worker.politeness.SetMinDelay(site.Host, rules.CrawlDelay)
+		}
+		return rules, robotsTTL
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return robots.AllowAll(), robotsTTL
+	}
+	r.ReportError(robotsStage, robotsURL, fmt.Errorf("unexpected status %s", resp.Status))
+	return robots.DisallowAll(), unreachableTTL
+}
+
+// cacheKey returns the key under which the robots.txt file of `site` is
+// cached.
+func cacheKey(site *url.URL) string {
+	return site.Scheme + "://" + site.Host
+}
+
+// cached returns the `*robots.Rules` that apply to `site` if its robots.txt
+// file has already been fetched and has not expired yet, or `false`
+// otherwise.
+//
+// NOTE: This function is thread-safe and never blocks on a fetch.
+func (r *Robots) cached(site *url.URL) (*robots.Rules, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.cache[cacheKey(site)]
+	if !ok {
+		return nil, false
+	}
+	select {
+	case <-e.ready:
+		return e.rules, time.Now().Before(e.expires)
+	default:
+		return nil, false
+	}
+}
+
+// Rules returns the `*robots.Rules` that apply to `site`, fetching its
+// robots.txt file if it is not cached yet, or `nil` if `ctx` is cancelled
+// while waiting for it.
+//
+// NOTE: This function is thread-safe. The robots.txt file of a site is only
+// fetched once at a time.
+func (r *Robots) Rules(ctx context.Context, site *url.URL) *robots.Rules {
+	key := cacheKey(site)
+
+	r.mu.Lock()
+	e, ok := r.cache[key]
+	if ok {
+		select {
+		case <-e.ready:
+			ok = time.Now().Before(e.expires)
+		default:
+		}
+	}
+	if !ok {
+		e = &robotsEntry{ready: make(chan struct{})}
+		r.cache[key] = e
+		r.mu.Unlock()
+
+		rules, ttl := r.fetch(ctx, site)
+		e.rules, e.expires = rules, time.Now().Add(ttl)
+		close(e.ready)
+		return rules
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-e.ready:
+		return e.rules
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// check sends `t`, located at `u`, to `out` if `rules` allow it and discards
+// it otherwise. `t` is discarded as cancelled when `rules` is `nil`.
+func (r *Robots) check(wg *sync.WaitGroup, t *domain.Target, u *url.URL, rules *robots.Rules, out chan<- *domain.Target) {
+	if rules == nil {
+		r.NotifyDiscard(robotsStage, t, ReasonCancelled)
+		wg.Done()
+		return
+	}
+
+	path := u.EscapedPath()
+	if len(path) == 0 {
+		path = "/"
+	}
+	if len(u.RawQuery) != 0 {
+		path += "?" + u.RawQuery
+	}
+	if !rules.Allowed(path) {
+		r.NotifyDiscard(robotsStage, t, ReasonRobots)
+		wg.Done()
+		return
+	}
+
+	r.NotifyLeave(robotsStage, t)
+	out <- t
+}
+
+// Pipe connects `in` and `out` together. Any `*domain.Target` received from
+// `in` will be checked against the robots.txt file of its host and discarded
+// if it is disallowed.
+//
+// NOTE: `*domain.Target`s whose scheme is neither `http` nor `https` are sent
+// to `out` unchecked.
+//
+// NOTE: `*domain.Target`s whose robots.txt file is not cached yet wait for it
+// in their own goroutine, so that a slow host does not hold back the others.
+// They may then be sent to `out` in a different order than received. At most
+// `internal.maxRobotsWaiters` of them wait at the same time, after which `in`
+// is not read until one of them is done.
+//
+// NOTE: This function will loop over a channel until `in` is closed. After that
+// it will close `out` once every `*domain.Target` has been checked.
+func (r *Robots) Pipe(ctx context.Context, wg *sync.WaitGroup, in <-chan *domain.Target, out chan<- *domain.Target) {
+	defer close(out)
+
+	waiting := sync.WaitGroup{}
+	defer waiting.Wait()
+	waiters := make(chan struct{}, maxRobotsWaiters)
+
+	for t := range in {
+		r.NotifyEnter(robotsStage, t)
+		if ctx.Err() != nil {
+			r.NotifyDiscard(robotsStage, t, ReasonCancelled)
+			wg.Done()
+			continue
+		}
+
+		u, err := url.Parse(t.BaseURL)
+		if err != nil {
+			r.ReportError(robotsStage, t.BaseURL, err)
+			r.NotifyDiscard(robotsStage, t, ReasonInvalidURL)
+			wg.Done()
+			continue
+		}
+
+		if u.Scheme != "http" && u.Scheme != "https" {
+			r.NotifyLeave(robotsStage, t)
+			out <- t
+		} else if rules, ok := r.cached(u); ok {
+			r.check(wg, t, u, rules, out)
+		} else {
+			waiters <- struct{}{}
+			waiting.Add(1)
+			go func(t *domain.Target, u *url.URL) {
+				defer waiting.Done()
+				defer func() { <-waiters }()
+				r.check(wg, t, u, r.Rules(ctx, u), out)
+			}(t, u)
+		}
+	}
+}
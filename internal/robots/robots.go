@@ -0,0 +1,184 @@
+package robots
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxSize is the maximum number of bytes of a robots.txt file that are parsed,
+// as allowed by RFC 9309.
+const MaxSize = 500 << 10
+
+// rule is a `struct` representing an `Allow` or `Disallow` line of a
+// robots.txt file.
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+// group is a `struct` representing the lines of a robots.txt file following
+// one or several `User-agent` lines. It is `closed` once a line other than
+// `User-agent` has been read, so that the next `User-agent` line starts a new
+// group.
+type group struct {
+	agents []string
+	rules  []rule
+	delay  time.Duration
+	closed bool
+}
+
+// Rules is a `struct` representing the part of a robots.txt file that applies
+// to a given user-agent.
+type Rules struct {
+	rules      []rule
+	CrawlDelay time.Duration
+	Sitemaps   []string
+}
+
+// AllowAll returns a new `*robots.Rules` allowing every path, as when a
+// robots.txt file is unavailable.
+func AllowAll() *Rules {
+	return &Rules{}
+}
+
+// DisallowAll returns a new `*robots.Rules` disallowing every path, as when a
+// robots.txt file is unreachable.
+func DisallowAll() *Rules {
+	return &Rules{rules: []rule{{allow: false, pattern: "/"}}}
+}
+
+// match returns `true` if `path` matches `pattern`, where `*` matches any
+// sequence of characters and a trailing `$` matches the end of `path`.
+func match(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	if len(parts) == 1 {
+		return !anchored || pos == len(path)
+	}
+
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(path[pos:], part)
+		if i < 0 {
+			return false
+		}
+		pos += i + len(part)
+	}
+
+	last := parts[len(parts)-1]
+	if anchored {
+		return len(path)-pos >= len(last) && strings.HasSuffix(path, last)
+	}
+	return strings.Contains(path[pos:], last)
+}
+
+// Allowed returns `true` if `path`, made of the path and query of an URL, can
+// be crawled according to `r`.
+//
+// NOTE: As defined by RFC 9309, the longest matching rule wins and `Allow`
+// wins over `Disallow` when both match with the same length.
+func (r *Rules) Allowed(path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+
+	allowed, longest := true, -1
+	for _, rl := range r.rules {
+		if !match(rl.pattern, path) {
+			continue
+		}
+		if len(rl.pattern) > longest || len(rl.pattern) == longest && rl.allow {
+			allowed, longest = rl.allow, len(rl.pattern)
+		}
+	}
+	return allowed
+}
+
+// Parse reads the robots.txt file from `r` and returns the `*robots.Rules`
+// applying to the `userAgent` product token, or an `error` if `r` cannot be
+// read. Groups matching `userAgent` are merged, and groups for `*` are used
+// when none does.
+//
+// NOTE: Only the first `robots.MaxSize` bytes of `r` are read.
+func Parse(r io.Reader, userAgent string) (*Rules, error) {
+	var groups []*group
+	var current *group
+	res := &Rules{}
+
+	scanner := bufio.NewScanner(io.LimitReader(r, MaxSize))
+	scanner.Buffer(make([]byte, 0, 4096), MaxSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		value := strings.TrimSpace(kv[1])
+
+		switch key {
+		case "user-agent":
+			if current == nil || current.closed {
+				current = &group{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			if current == nil {
+				continue
+			}
+			current.closed = true
+			if len(value) != 0 {
+				current.rules = append(current.rules, rule{allow: key == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			if current == nil {
+				continue
+			}
+			current.closed = true
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.delay = time.Duration(seconds * float64(time.Second))
+			}
+		case "sitemap":
+			res.Sitemaps = append(res.Sitemaps, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Parse: %v", err)
+	}
+
+	for _, agent := range []string{strings.ToLower(userAgent), "*"} {
+		matched := false
+		for _, g := range groups {
+			for _, a := range g.agents {
+				if a == agent {
+					matched = true
+					res.rules = append(res.rules, g.rules...)
+					if g.delay > res.CrawlDelay {
+						res.CrawlDelay = g.delay
+					}
+					break
+				}
+			}
+		}
+		if matched {
+			break
+		}
+	}
+	return res, nil
+}
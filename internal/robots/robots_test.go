@@ -0,0 +1,109 @@
+package robots
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRobots_match(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mockPattern string
+		mockPath    string
+		expected    bool
+	}{
+		{"prefix", "/fish", "/fish.html", true},
+		{"prefixNoMatch", "/fish", "/Fish.asp", false},
+		{"wildcard", "/fish*.php", "/fish/salmon.php", true},
+		{"wildcardNoMatch", "/fish*.php", "/fish.asp", false},
+		{"wildcardQuery", "/*.php", "/index.php?params", true},
+		{"anchored", "/*.php$", "/filename.php", true},
+		{"anchoredNoMatch", "/*.php$", "/filename.php?params", false},
+		{"anchoredExact", "/fish$", "/fish", true},
+		{"anchoredExactNoMatch", "/fish$", "/fishes", false},
+		{"severalWildcards", "/a*b*c", "/axxbyyc", true},
+		{"severalWildcardsNoMatch", "/a*b*c", "/axxcyyb", false},
+		{"trailingWildcard", "/a*", "/a", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, match(tc.mockPattern, tc.mockPath))
+		})
+	}
+}
+
+func TestRobots_Parse(t *testing.T) {
+	mockFile := `# comment
+User-agent: mcrawler
+User-agent: otherbot
+Disallow: /private
+Allow: /private/public # inline comment
+Disallow: /*.pdf$
+Crawl-delay: 1.5
+
+User-agent: *
+Disallow: /
+
+Sitemap: http://www.a.com/sitemap.xml
+`
+
+	testCases := []struct {
+		name          string
+		mockAgent     string
+		mockPath      string
+		expected      bool
+		expectedDelay time.Duration
+	}{
+		{"allowedPath", "mcrawler", "/index.html", true, 1500 * time.Millisecond},
+		{"disallowedPath", "mcrawler", "/private/secret", false, 1500 * time.Millisecond},
+		{"longestAllow", "MCrawler", "/private/public/a", true, 1500 * time.Millisecond},
+		{"wildcardDisallow", "otherbot", "/doc.pdf", false, 1500 * time.Millisecond},
+		{"robotsAlwaysAllowed", "otherbot", "/robots.txt", true, 1500 * time.Millisecond},
+		{"defaultGroup", "unknownbot", "/index.html", false, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := Parse(strings.NewReader(mockFile), tc.mockAgent)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, r.Allowed(tc.mockPath))
+			assert.Equal(t, tc.expectedDelay, r.CrawlDelay)
+			assert.Equal(t, []string{"http://www.a.com/sitemap.xml"}, r.Sitemaps)
+		})
+	}
+}
+
+func TestRobots_Parse_groups(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mockFile string
+		mockPath string
+		expected bool
+	}{
+		{"empty", "", "/a", true},
+		{"rulesWithoutGroup", "Disallow: /a", "/a", true},
+		{"emptyDisallow", "User-agent: mcrawler\nDisallow:\n\nUser-agent: *\nDisallow: /", "/a", true},
+		{"mergedGroups", "User-agent: mcrawler\nDisallow: /a\n\nUser-agent: mcrawler\nDisallow: /b", "/b", false},
+		{"equalLengthAllowWins", "User-agent: *\nDisallow: /a\nAllow: /a", "/a", true},
+		{"caseInsensitiveKeys", "USER-AGENT: *\ndisallow: /a", "/a", false},
+		{"newGroupAfterRules", "User-agent: mcrawler\nDisallow: /a\nUser-agent: otherbot\nDisallow: /b", "/b", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := Parse(strings.NewReader(tc.mockFile), "mcrawler")
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, r.Allowed(tc.mockPath))
+		})
+	}
+}
+
+func TestRobots_AllowAll_DisallowAll(t *testing.T) {
+	assert.True(t, AllowAll().Allowed("/a"))
+	assert.False(t, DisallowAll().Allowed("/a"))
+	assert.True(t, DisallowAll().Allowed("/robots.txt"))
+}
@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timtosi/mcrawler/internal/domain"
+)
+
+func TestRobots_Pipe(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mockStatus  int
+		mockRobots  string
+		mockPaths   []string
+		expectedOut []string
+	}{
+		{
+			"disallowed",
+			http.StatusOK,
+			"User-agent: *\nDisallow: /private\n",
+			[]string{"/public", "/private", "/private?q=1", "/"},
+			[]string{"/public", "/"},
+		},
+		{
+			"notFound",
+			http.StatusNotFound,
+			"",
+			[]string{"/public", "/private"},
+			[]string{"/public", "/private"},
+		},
+		{
+			"serverError",
+			http.StatusServiceUnavailable,
+			"",
+			[]string{"/public", "/private"},
+			nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var fetched int32
+			ms := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Path == "/robots.txt" {
+						atomic.AddInt32(&fetched, 1)
+						w.WriteHeader(tc.mockStatus)
+						w.Write([]byte(tc.mockRobots))
+					}
+				}),
+			)
+			defer ms.Close()
+
+			var targets []*domain.Target
+			for _, path := range tc.mockPaths {
+				targets = append(targets, domain.NewTarget(ms.URL+path))
+			}
+
+			var res []string
+			for _, tgt := range runPipe(t, NewRobots(NewWorker()), targets...) {
+				res = append(res, tgt.BaseURL[len(ms.URL):])
+			}
+			assert.ElementsMatch(t, tc.expectedOut, res)
+			assert.Equal(t, int32(1), fetched)
+		})
+	}
+}
+
+func TestRobots_WithRobotsAgent(t *testing.T) {
+	ms := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("User-agent: mybot\nDisallow: /\nCrawl-delay: 2\n"))
+		}),
+	)
+	defer ms.Close()
+
+	p := NewPoliteness(HostPolicy{})
+	w := NewWorker(WithPoliteness(p))
+	tgt := domain.NewTarget(ms.URL + "/page")
+
+	assert.Len(t, runPipe(t, NewRobots(w), tgt), 1)
+	assert.Len(t, runPipe(t, NewRobots(w, WithRobotsAgent("mybot")), tgt), 0)
+
+	host := ms.Listener.Addr().String()
+	assert.Equal(t, 2*time.Second, p.policies[host].Delay)
+}
+
+func TestRobots_Pipe_cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	inChan := make(chan *domain.Target)
+	outChan := make(chan *domain.Target)
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+
+	go NewRobots(NewWorker()).Pipe(ctx, &wg, inChan, outChan)
+	inChan <- domain.NewTarget("http://localhost:1/page")
+	close(inChan)
+
+	_, ok := <-outChan
+	assert.False(t, ok)
+	wg.Wait()
+}
+
+func TestRobots_redirect(t *testing.T) {
+	www := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+		}),
+	)
+	defer www.Close()
+	ms := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, www.URL+r.URL.Path, http.StatusMovedPermanently)
+		}),
+	)
+	defer ms.Close()
+
+	f, err := NewFollower(ms.URL)
+	if err != nil {
+		t.Fatalf("TestRobots_redirect: %v", err)
+	}
+	a := NewArchiver()
+	w := NewWorker(WithMaxRedirects(0), WithRedirectCheckers(f, a))
+
+	var res []string
+	for _, tgt := range runPipe(t, NewRobots(w), domain.NewTarget(ms.URL+"/public"), domain.NewTarget(ms.URL+"/private")) {
+		res = append(res, tgt.BaseURL[len(ms.URL):])
+	}
+	assert.Equal(t, []string{"/public"}, res)
+	assert.False(t, a.IsAlreadySeen(www.URL+"/robots.txt"))
+}
+
+func TestRobots_Pipe_slowHost(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			w.WriteHeader(http.StatusNotFound)
+		}),
+	)
+	defer slow.Close()
+	defer close(release)
+	fast := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}),
+	)
+	defer fast.Close()
+
+	inChan := make(chan *domain.Target)
+	outChan := make(chan *domain.Target)
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go NewRobots(NewWorker()).Pipe(context.Background(), &wg, inChan, outChan)
+
+	inChan <- domain.NewTarget(slow.URL + "/page")
+	inChan <- domain.NewTarget(fast.URL + "/page")
+	select {
+	case res := <-outChan:
+		assert.Equal(t, fast.URL+"/page", res.BaseURL)
+	case <-time.After(time.Second):
+		t.Fatalf("TestRobots_Pipe_slowHost: pipe blocked by a slow host")
+	}
+
+	release <- struct{}{}
+	res := <-outChan
+	assert.Equal(t, slow.URL+"/page", res.BaseURL)
+	close(inChan)
+	_, ok := <-outChan
+	assert.False(t, ok)
+}
@@ -0,0 +1,135 @@
+package sitemap
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// MaxSize is the maximum number of uncompressed bytes of a sitemap that are
+// parsed, as defined by the sitemaps protocol.
+const MaxSize = 50 << 20
+
+// MaxSitemaps is the maximum number of sitemaps fetched by `sitemap.Fetch`,
+// which prevents sitemap indexes from being followed indefinitely.
+const MaxSitemaps = 1000
+
+// Document is a `struct` representing a parsed sitemap. A `urlset` document
+// only has `URLs` while a `sitemapindex` document only has `Sitemaps`.
+type Document struct {
+	URLs     []string
+	Sitemaps []string
+}
+
+// loc is a `struct` representing the `<url>` and `<sitemap>` elements of a
+// sitemap.
+type loc struct {
+	Loc string `xml:"loc"`
+}
+
+// Parse reads a `urlset` or `sitemapindex` document from `r` and returns it
+// as a `*sitemap.Document` or an `error` if it cannot be parsed. Gzip
+// compressed documents are decompressed.
+//
+// NOTE: Only the first `sitemap.MaxSize` uncompressed bytes of `r` are read.
+func Parse(r io.Reader) (*Document, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("Parse: %v", err)
+		}
+		defer gr.Close()
+		r = gr
+	} else {
+		r = br
+	}
+
+	var doc struct {
+		XMLName  xml.Name
+		URLs     []loc `xml:"url"`
+		Sitemaps []loc `xml:"sitemap"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(r, MaxSize)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("Parse: %v", err)
+	}
+
+	res := &Document{}
+	switch doc.XMLName.Local {
+	case "urlset":
+		for _, u := range doc.URLs {
+			if l := strings.TrimSpace(u.Loc); len(l) != 0 {
+				res.URLs = append(res.URLs, l)
+			}
+		}
+	case "sitemapindex":
+		for _, s := range doc.Sitemaps {
+			if l := strings.TrimSpace(s.Loc); len(l) != 0 {
+				res.Sitemaps = append(res.Sitemaps, l)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("Parse: unknown root element %q", doc.XMLName.Local)
+	}
+	return res, nil
+}
+
+// get fetches and parses the sitemap located at `sitemapURL` with `client`.
+func get(ctx context.Context, client *http.Client, sitemapURL string) (*Document, error) {
+	req, err := http.NewRequest(http.MethodGet, sitemapURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected status %s", sitemapURL, resp.Status)
+	}
+	return Parse(resp.Body)
+}
+
+// Fetch fetches the sitemaps located at `sitemapURLs` with `client`, following
+// sitemap indexes, and returns every page URL found. It also returns an
+// `error` describing the sitemaps that could not be fetched, if any, in which
+// case the URLs found in the other sitemaps are still returned.
+//
+// NOTE: At most `sitemap.MaxSitemaps` sitemaps are fetched.
+func Fetch(ctx context.Context, client *http.Client, sitemapURLs ...string) ([]string, error) {
+	var urls, failures []string
+	seen := make(map[string]bool)
+	queue := append([]string(nil), sitemapURLs...)
+
+	for len(queue) != 0 && len(seen) < MaxSitemaps && ctx.Err() == nil {
+		sitemapURL := queue[0]
+		queue = queue[1:]
+		if seen[sitemapURL] {
+			continue
+		}
+		seen[sitemapURL] = true
+
+		doc, err := get(ctx, client, sitemapURL)
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		urls = append(urls, doc.URLs...)
+		queue = append(queue, doc.Sitemaps...)
+	}
+
+	if ctx.Err() != nil {
+		return urls, fmt.Errorf("Fetch: %v", ctx.Err())
+	} else if len(failures) != 0 {
+		return urls, fmt.Errorf("Fetch: %s", strings.Join(failures, "; "))
+	}
+	return urls, nil
+}
@@ -0,0 +1,125 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	mockURLSet = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url><loc>http://www.a.com/</loc></url>
+	<url><loc> http://www.a.com/about </loc><lastmod>2020-01-01</lastmod></url>
+	<url><loc></loc></url>
+</urlset>`
+	mockIndex = `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap><loc>http://www.a.com/sitemap1.xml</loc></sitemap>
+	<sitemap><loc>http://www.a.com/sitemap2.xml.gz</loc></sitemap>
+</sitemapindex>`
+)
+
+// gzipped is an helper function only used for test purposes. It returns `s`
+// compressed with gzip.
+func gzipped(s string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write([]byte(s))
+	gw.Close()
+	return buf.Bytes()
+}
+
+func TestSitemap_Parse(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mockContent []byte
+		expectedDoc *Document
+		expectedErr bool
+	}{
+		{
+			"urlset",
+			[]byte(mockURLSet),
+			&Document{URLs: []string{"http://www.a.com/", "http://www.a.com/about"}},
+			false,
+		},
+		{
+			"sitemapindex",
+			[]byte(mockIndex),
+			&Document{Sitemaps: []string{"http://www.a.com/sitemap1.xml", "http://www.a.com/sitemap2.xml.gz"}},
+			false,
+		},
+		{
+			"gzip",
+			gzipped(mockURLSet),
+			&Document{URLs: []string{"http://www.a.com/", "http://www.a.com/about"}},
+			false,
+		},
+		{"unknownRoot", []byte(`<html></html>`), nil, true},
+		{"notXML", []byte(`User-agent: *`), nil, true},
+		{"empty", nil, nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(bytes.NewReader(tc.mockContent))
+			assert.Equal(t, tc.expectedErr, err != nil)
+			assert.Equal(t, tc.expectedDoc, doc)
+		})
+	}
+}
+
+func TestSitemap_Fetch(t *testing.T) {
+	var ms *httptest.Server
+	ms = httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/index.xml":
+				w.Write([]byte(strings.Replace(mockIndex, "http://www.a.com", ms.URL, -1)))
+			case "/loop.xml":
+				w.Write([]byte(strings.Replace(
+					`<sitemapindex><sitemap><loc>http://www.a.com/loop.xml</loc></sitemap></sitemapindex>`,
+					"http://www.a.com", ms.URL, -1,
+				)))
+			case "/sitemap1.xml":
+				w.Write([]byte(`<urlset><url><loc>http://www.a.com/one</loc></url></urlset>`))
+			case "/sitemap2.xml.gz":
+				w.Header().Set("Content-Type", "application/gzip")
+				w.Write(gzipped(`<urlset><url><loc>http://www.a.com/two</loc></url></urlset>`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}),
+	)
+	defer ms.Close()
+
+	testCases := []struct {
+		name         string
+		mockSitemaps []string
+		expectedURLs []string
+		expectedErr  bool
+	}{
+		{"index", []string{"/index.xml"}, []string{"http://www.a.com/one", "http://www.a.com/two"}, false},
+		{"loop", []string{"/loop.xml"}, nil, false},
+		{"notFound", []string{"/nope.xml", "/sitemap1.xml"}, []string{"http://www.a.com/one"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var sitemapURLs []string
+			for _, s := range tc.mockSitemaps {
+				sitemapURLs = append(sitemapURLs, ms.URL+s)
+			}
+
+			urls, err := Fetch(context.Background(), ms.Client(), sitemapURLs...)
+			assert.Equal(t, tc.expectedErr, err != nil)
+			assert.Equal(t, tc.expectedURLs, urls)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// StatusPolicy is a named type deciding whether a web page answered with a
+// given status code has been fetched successfully.
+type StatusPolicy func(code int) bool
+
+// DefaultStatusPolicy is the `internal.StatusPolicy` used by
+// `*internal.Worker`s by default. It accepts every `2xx` status code.
+func DefaultStatusPolicy(code int) bool { return code >= 200 && code < 300 }
+
+// ParseStatusPolicy parses `s`, a comma-separated list of status codes
+// (`200`), ranges (`200-299`) or classes (`2xx`), and returns the
+// `internal.StatusPolicy` accepting any of them or an `error` if `s` is
+// malformed.
+func ParseStatusPolicy(s string) (StatusPolicy, error) {
+	type codeRange struct{ min, max int }
+
+	var ranges []codeRange
+	for _, field := range strings.Split(s, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+
+		var r codeRange
+		var err error
+		switch {
+		case len(field) == 3 && strings.HasSuffix(field, "xx"):
+			r.min, err = strconv.Atoi(field[:1])
+			r.min *= 100
+			r.max = r.min + 99
+		case strings.Contains(field, "-"):
+			bounds := strings.SplitN(field, "-", 2)
+			if r.min, err = strconv.Atoi(bounds[0]); err == nil {
+				r.max, err = strconv.Atoi(bounds[1])
+			}
+		default:
+			r.min, err = strconv.Atoi(field)
+			r.max = r.min
+		}
+
+		if err != nil || r.min < 100 || r.max > 599 || r.min > r.max {
+			return nil, fmt.Errorf("ParseStatusPolicy: invalid status %q", field)
+		}
+		ranges = append(ranges, r)
+	}
+
+	return func(code int) bool {
+		for _, r := range ranges {
+			if code >= r.min && code <= r.max {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// StatusError is an `error` returned by `Worker.Fetch` when a web page is
+// answered with a status code rejected by the `internal.StatusPolicy` of the
+// `*internal.Worker`.
+type StatusError struct {
+	Code int
+}
+
+// Error implements the `error` interface.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d %s", e.Code, http.StatusText(e.Code))
+}
@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultStatusPolicy(t *testing.T) {
+	for code, expected := range map[int]bool{199: false, 200: true, 204: true, 299: true, 301: false, 404: false, 500: false} {
+		assert.Equal(t, expected, DefaultStatusPolicy(code), code)
+	}
+}
+
+func TestParseStatusPolicy(t *testing.T) {
+	testCases := []struct {
+		name          string
+		mockPolicy    string
+		expectedCodes map[int]bool
+		expectedErr   bool
+	}{
+		{"code", "200", map[int]bool{200: true, 201: false}, false},
+		{"class", "2xx", map[int]bool{199: false, 200: true, 299: true, 300: false}, false},
+		{"range", "200-204", map[int]bool{200: true, 204: true, 205: false}, false},
+		{"list", " 2XX, 304,404-410 ", map[int]bool{250: true, 304: true, 305: false, 404: true, 410: true, 411: false}, false},
+		{"empty", "", nil, true},
+		{"notNumber", "ok", nil, true},
+		{"badClass", "9xx", nil, true},
+		{"reversedRange", "299-200", nil, true},
+		{"outOfBounds", "600", nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := ParseStatusPolicy(tc.mockPolicy)
+			assert.Equal(t, tc.expectedErr, err != nil)
+			for code, expected := range tc.expectedCodes {
+				assert.Equal(t, expected, p(code), code)
+			}
+		})
+	}
+}
+
+func TestStatusError_Error(t *testing.T) {
+	assert.Equal(t, "unexpected status 404 Not Found", (&StatusError{Code: http.StatusNotFound}).Error())
+}
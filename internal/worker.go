@@ -1,27 +1,58 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"sync"
 	"time"
 
+	"github.com/timtosi/mcrawler/internal/cache"
 	"github.com/timtosi/mcrawler/internal/domain"
 )
 
+// workerStage is the name used by `*internal.Worker` to report errors and
+// notify events.
+const workerStage = "worker"
+
+// DefaultConcurrency is the number of web pages fetched at the same time by an
+// `*internal.Worker` unless `internal.WithConcurrency` is given.
+const DefaultConcurrency = 16
+
 // Worker is a `struct` representing a HTTP client concurrently fetching
 // web pages.
 type Worker struct {
 	http.Client
+	Reporter
+
+	budget      *Budget
+	politeness  *Politeness
+	cache       *cache.Cache
+	status      StatusPolicy
+	concurrency int
+
+	maxRedirects     int
+	redirectCheckers []RedirectChecker
+	retry            RetryPolicy
+
+	maxBodySize  int64
+	contentTypes []string
+	headNonHTML  bool
+	checkAssets  bool
+
+	identity *identity
+	proxies  *proxies
 }
 
 // NewWorker returns a new `*crawler.Worker` that can be configured
 // through `opts` functions.
 func NewWorker(opts ...func(*Worker)) *Worker {
+	px := newProxies()
 	tr := &http.Transport{
+		Proxy:                 px.proxy,
 		DisableKeepAlives:     true,
 		MaxIdleConnsPerHost:   1,
 		TLSHandshakeTimeout:   10 * time.Second,
@@ -33,55 +64,478 @@ func NewWorker(opts ...func(*Worker)) *Worker {
 		}).Dial,
 	}
 
-	w := &Worker{Client: http.Client{
-		Transport: tr,
-		Timeout:   15 * time.Second,
-	}}
+	w := &Worker{
+		Client: http.Client{
+			Transport: tr,
+			Timeout:   15 * time.Second,
+		},
+		status:       DefaultStatusPolicy,
+		maxRedirects: 10,
+		concurrency:  DefaultConcurrency,
+		identity:     newIdentity(nil),
+		proxies:      px,
+	}
+	w.CheckRedirect = w.checkRedirect
 
 	for _, opt := range opts {
 		opt(w)
 	}
+	w.identity.base, w.Transport = w.Transport, w.identity
 	return w
 }
 
+// WithBudget makes a `*internal.Worker` account for every page fetched and
+// every byte downloaded in `b`. No page is fetched anymore once `b` is
+// exhausted.
+func WithBudget(b *Budget) func(*Worker) {
+	return func(w *Worker) { w.budget = b }
+}
+
+// WithPoliteness makes a `*internal.Worker` wait for `p` before fetching a web
+// page, so that hosts are not crawled faster than allowed.
+func WithPoliteness(p *Politeness) func(*Worker) {
+	return func(w *Worker) { w.politeness = p }
+}
+
+// WithCache makes a `*internal.Worker` send conditional requests for the web
+// pages found in `c` and store in `c` the web pages it fetches, under both
+// their URL and their final URL when they are redirected. When a web page has
+// not been modified, its content is taken from `c` and it is marked as
+// `domain.Target.Unchanged`. Web pages answered with `404 Not Found` or
+// `410 Gone` are removed from `c`.
+func WithCache(c *cache.Cache) func(*Worker) {
+	return func(w *Worker) { w.cache = c }
+}
+
+// WithStatusPolicy makes a `*internal.Worker` only consider as successfully
+// fetched the web pages answered with a status code accepted by `p`, instead of
+// `internal.DefaultStatusPolicy`.
+func WithStatusPolicy(p StatusPolicy) func(*Worker) {
+	return func(w *Worker) { w.status = p }
+}
+
+// WithMaxRedirects makes a `*internal.Worker` follow at most `n` redirects
+// when fetching a web page instead of 10. Redirects are not followed at all
+// when `n` is 0.
+func WithMaxRedirects(n int) func(*Worker) {
+	return func(w *Worker) { w.maxRedirects = n }
+}
+
+// WithRedirectCheckers makes a `*internal.Worker` check every URL a web page
+// redirects to with `rc`, so that the pipeline rules apply to the page
+// eventually fetched as well, e.g. its host or whether it has already been
+// seen.
+func WithRedirectCheckers(rc ...RedirectChecker) func(*Worker) {
+	return func(w *Worker) { w.redirectCheckers = append(w.redirectCheckers, rc...) }
+}
+
+// WithRetry makes a `*internal.Worker` fetch again the web pages that could
+// not be fetched because of a network error, a `429 Too Many Requests` or a
+// `5xx` response, according to `p`.
+func WithRetry(p RetryPolicy) func(*Worker) {
+	return func(w *Worker) { w.retry = p }
+}
+
+// WithMaxBodySize makes a `*internal.Worker` read at most `n` bytes of a web
+// page. Longer web pages are cut and marked as `domain.Target.Truncated`. No
+// limit is applied when `n` is lower than 1.
+func WithMaxBodySize(n int64) func(*Worker) {
+	return func(w *Worker) { w.maxBodySize = n }
+}
+
+// WithContentTypes makes a `*internal.Worker` only download the web pages
+// whose `Content-Type` is one of `types`, e.g. `text/html` or `image/*`. The
+// others are marked as `domain.Target.Skipped` as soon as their headers are
+// received, without reading their body.
+func WithContentTypes(types ...string) func(*Worker) {
+	return func(w *Worker) { w.contentTypes = append(w.contentTypes, types...) }
+}
+
+// WithHeadNonHTML makes a `*internal.Worker` send a `HEAD` request instead of
+// a `GET` for the resources whose URL extension is known not to be HTML, e.g.
+// images. Unknown and dynamic extensions, e.g. `.php`, are fetched with a
+// `GET`. Their status code and headers are recorded and they are marked as
+// `domain.Target.Skipped`.
+func WithHeadNonHTML() func(*Worker) {
+	return func(w *Worker) { w.headNonHTML = true }
+}
+
+// WithAssetCheck makes a `*internal.Worker` only check that assets, e.g.
+// images, PDFs or archives, can be fetched instead of downloading them. Assets
+// are recognized by the extension of their URL and checked with a `HEAD`
+// request, or with a `GET` request for their first byte when the host does not
+// allow `HEAD`. A `206 Partial Content` answer to the latter is always
+// accepted, whatever the `internal.StatusPolicy`. Web pages answered with a `Content-Type` that is not HTML are
+// considered as assets as well, and their body is not read. Their status code
+// and headers are recorded and they are marked as `domain.Target.Asset`.
+func WithAssetCheck() func(*Worker) {
+	return func(w *Worker) { w.checkAssets = true }
+}
+
+// WithUserAgent makes a `*internal.Worker` send `ua` as the `User-Agent` of
+// its requests instead of the default one of `net/http`.
+//
+// NOTE: The rules of robots.txt files followed are still the ones given to
+// the user-agent token of `internal.WithRobotsAgent`.
+func WithUserAgent(ua string) func(*Worker) {
+	return func(w *Worker) { w.identity.userAgent = ua }
+}
+
+// WithHeader makes a `*internal.Worker` add the header `key` with `value` to
+// every request it sends.
+func WithHeader(key, value string) func(*Worker) {
+	return func(w *Worker) { w.identity.headers.Add(key, value) }
+}
+
+// WithHostHeader makes a `*internal.Worker` add the header `key` with `value`
+// to the requests it sends to `host`, given with or without port. It replaces
+// any header with the same `key` given with `internal.WithHeader`.
+func WithHostHeader(host, key, value string) func(*Worker) {
+	return func(w *Worker) { w.identity.addHostHeader(host, key, value) }
+}
+
+// WithCookieJar makes a `*internal.Worker` store the cookies it receives in
+// `jar` and send them back in the following requests, e.g. a
+// `*cookiejar.Jar`.
+func WithCookieJar(jar http.CookieJar) func(*Worker) {
+	return func(w *Worker) { w.Jar = jar }
+}
+
+// WithBasicAuth makes a `*internal.Worker` authenticate with the HTTP Basic
+// authentication of `user` and `password` to `host`, given with or without
+// port.
+//
+// NOTE: Credentials are only sent to `host`, including when a web page of
+// `host` links or redirects to another one.
+func WithBasicAuth(host, user, password string) func(*Worker) {
+	return func(w *Worker) { w.identity.credentials[hostKey(host)] = basicAuth(user, password) }
+}
+
+// WithBearerToken makes a `*internal.Worker` authenticate with `token` as a
+// bearer token to `host`, given with or without port.
+//
+// NOTE: Credentials are only sent to `host`, including when a web page of
+// `host` links or redirects to another one.
+func WithBearerToken(host, token string) func(*Worker) {
+	return func(w *Worker) { w.identity.credentials[hostKey(host)] = "Bearer " + token }
+}
+
+// WithProxy makes a `*internal.Worker` send its requests through the proxy
+// located at `u` instead of the one given by the `HTTP_PROXY`, `HTTPS_PROXY`
+// and `NO_PROXY` environment variables. The scheme of `u` is either `http`,
+// `https` or `socks5`, and credentials can be given as its user info. Requests
+// are sent directly when `u` is `nil`.
+func WithProxy(u *url.URL) func(*Worker) {
+	return func(w *Worker) { w.proxies.fallback = http.ProxyURL(u) }
+}
+
+// WithHostProxy makes a `*internal.Worker` send its requests to `host`, given
+// with or without port, through the proxy located at `u`, or directly when `u`
+// is `nil`. It takes precedence over `internal.WithProxy` and the environment
+// variables.
+func WithHostProxy(host string, u *url.URL) func(*Worker) {
+	return func(w *Worker) { w.proxies.hosts[hostKey(host)] = u }
+}
+
+// WithConcurrency makes a `*internal.Worker` fetch at most `n` web pages at
+// the same time instead of `internal.DefaultConcurrency`. No limit is applied
+// when `n` is lower than 1.
+func WithConcurrency(n int) func(*Worker) {
+	return func(w *Worker) { w.concurrency = n }
+}
+
 // Fetch performs a `GET` request on the web page located at `t.BaseURL` and
-// populates its `t.Content` or returns an `error` if something bad occurs.
-func (w *Worker) Fetch(t *domain.Target) error {
-	resp, err := w.Get(t.BaseURL)
+// populates its `t.StatusCode`, `t.Header` and `t.Content` or returns an
+// `error` if something bad occurs.
+//
+// NOTE: When the status code of the response is rejected by the
+// `internal.StatusPolicy` of `w`, a `*internal.StatusError` is returned as is
+// and `t.Content` is left empty.
+//
+// NOTE: The request is aborted as soon as `ctx` is cancelled, including while
+// waiting for `w.politeness`.
+//
+// NOTE: When `w.budget` is exhausted, the `internal.ErrMax*` error that
+// exhausted it is returned as is. A page only counts once in `w.budget`, on
+// its first attempt.
+//
+// NOTE: The redirects followed are recorded in `t.Redirects`. When a redirect
+// is not followed, a `*internal.RedirectError` is returned as is.
+//
+// NOTE: Web pages whose `Content-Type` is not accepted, fetched with a `HEAD`
+// request because of `internal.WithHeadNonHTML` or checked as assets because
+// of `internal.WithAssetCheck`, are marked as `t.Skipped` and their body is
+// not read. Web pages larger than
+// `w.maxBodySize` are cut and marked as `t.Truncated`. Neither is stored in
+// `w.cache`.
+//
+// NOTE: Every request sent is notified to the `internal.Observer`s of `w` as
+// an `internal.EventRequest`, followed by an `internal.EventFetch` once it is
+// over.
+func (w *Worker) Fetch(ctx context.Context, t *domain.Target) error {
+	if w.budget != nil && t.Attempts == 0 {
+		if err := w.budget.ReservePage(); err != nil {
+			return err
+		}
+	} else if w.budget != nil {
+		if err := w.budget.Err(); err != nil {
+			return err
+		}
+	}
+
+	t.StatusCode, t.Header, t.Skipped, t.Truncated = 0, nil, false, false
+	t.Asset = w.checkAssets && isAsset(t.BaseURL)
+	method := http.MethodGet
+	if t.Asset || (w.headNonHTML && !looksHTML(t.BaseURL)) {
+		method = http.MethodHead
+	}
+	req, err := http.NewRequest(method, t.BaseURL, nil)
 	if err != nil {
 		return fmt.Errorf("Fetch: %v", err)
 	}
 
-	t.Content, err = ioutil.ReadAll(resp.Body)
+	var entry *cache.Entry
+	if w.cache != nil && method == http.MethodGet {
+		if e, ok := w.cache.Get(t.BaseURL); ok {
+			entry = e
+			if len(e.ETag) != 0 {
+				req.Header.Set("If-None-Match", e.ETag)
+			}
+			if len(e.LastModified) != 0 {
+				req.Header.Set("If-Modified-Since", e.LastModified)
+			}
+		}
+	}
+
+	if w.politeness != nil && !isPaced(ctx) {
+		release, err := w.politeness.Wait(ctx, req.URL.Host)
+		if err != nil {
+			return fmt.Errorf("Fetch: %v", err)
+		}
+		defer release()
+	}
+
+	ctx = withClaimed(ctx, t.Redirects)
+	start := time.Now()
+	resp, err := w.send(t, req.WithContext(ctx))
 	if err != nil {
-		return fmt.Errorf("Fetch: %v", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	ranged := false
+	if t.Asset && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		w.NotifyFetch(workerStage, t, resp.StatusCode, 0, time.Since(start))
+		resp.Body.Close()
+
+		if req, err = http.NewRequest(http.MethodGet, t.BaseURL, nil); err != nil {
+			return fmt.Errorf("Fetch: %v", err)
+		}
+		req.Header.Set("Range", "bytes=0-0")
+
+		start, ranged = time.Now(), true
+		if resp, err = w.send(t, req.WithContext(ctx)); err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+	}
+
+	if w.checkAssets && !t.Asset && isAssetType(resp.Header) {
+		t.Asset = true
 	}
 
-	if err = resp.Body.Close(); err != nil {
+	var content []byte
+	var truncated bool
+	skipped := t.Asset || method == http.MethodHead || !acceptContentType(w.contentTypes, resp.Header)
+	if !skipped {
+		content, truncated, err = readBody(resp.Body, w.maxBodySize)
+	}
+	w.NotifyFetch(workerStage, t, resp.StatusCode, len(content), time.Since(start))
+	if err != nil {
 		return fmt.Errorf("Fetch: %v", err)
 	}
+
+	if w.budget != nil {
+		if err := w.budget.AddBytes(len(content)); err != nil {
+			return err
+		}
+	}
+
+	t.StatusCode, t.Header = resp.StatusCode, resp.Header
+	if entry != nil && resp.StatusCode == http.StatusNotModified {
+		t.Content, t.Unchanged = entry.Content, true
+		w.cache.Keep(t.FinalURL())
+		return nil
+	} else if w.cache != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone) {
+		w.cache.Delete(t.BaseURL)
+		w.cache.Delete(t.FinalURL())
+	}
+
+	if !w.status(resp.StatusCode) && !(ranged && resp.StatusCode == http.StatusPartialContent) {
+		return &StatusError{Code: resp.StatusCode}
+	} else if skipped {
+		t.Skipped = true
+		return nil
+	}
+
+	t.Content, t.Truncated = content, truncated
+	if w.cache != nil && resp.StatusCode == http.StatusOK && !truncated {
+		t.Unchanged = !w.cache.Put(t.FinalURL(), resp.Header, content)
+		w.cache.Alias(t.BaseURL, t.FinalURL())
+	}
 	return nil
 }
 
+// send sends `req` on behalf of `t` and records the redirects followed in
+// `t.Redirects`. Requests are notified to the `internal.Observer`s of `w` as an
+// `internal.EventRequest`, and as an `internal.EventFetch` as well when they
+// fail.
+func (w *Worker) send(t *domain.Target, req *http.Request) (*http.Response, error) {
+	w.NotifyRequest(workerStage, t)
+	start := time.Now()
+	resp, err := w.Do(req)
+	if err != nil {
+		var re *RedirectError
+		if errors.As(err, &re) {
+			w.NotifyFetch(workerStage, t, resp.StatusCode, 0, time.Since(start))
+			t.Redirects = re.Chain
+			return nil, re
+		}
+		w.NotifyFetch(workerStage, t, 0, 0, time.Since(start))
+		return nil, fmt.Errorf("Fetch: %v", err)
+	}
+	t.Redirects = responseChain(resp)
+	return resp, nil
+}
+
+// isPageError returns `true` if `err`, returned by `w.Fetch`, is caused by the
+// fetched web page rather than by the crawl being cancelled or out of budget.
+func (w *Worker) isPageError(ctx context.Context, err error) bool {
+	switch {
+	case ctx.Err() != nil:
+		return false
+	case err == ErrMaxPages, err == ErrMaxBytes, err == ErrMaxDuration:
+		return false
+	}
+	return true
+}
+
+// retryable returns `true` if `err`, returned by `w.Fetch`, is caused by a
+// network error, a `429 Too Many Requests` or a `5xx` response.
+func (w *Worker) retryable(ctx context.Context, err error) bool {
+	var se *StatusError
+	var re *RedirectError
+	switch {
+	case !w.isPageError(ctx, err):
+		return false
+	case errors.As(err, &se):
+		return se.Code == http.StatusTooManyRequests || se.Code >= 500
+	case errors.As(err, &re):
+		return false
+	}
+	return true
+}
+
+// discard removes `t` from the pipeline because of `err`, returned by
+// `w.Fetch`, and reports it as a `*internal.CrawlError` if it is caused by the
+// fetched web page.
+func (w *Worker) discard(ctx context.Context, wg *sync.WaitGroup, t *domain.Target, err error) {
+	defer wg.Done()
+
+	var se *StatusError
+	var re *RedirectError
+	switch {
+	case !w.isPageError(ctx, err):
+		w.NotifyDiscard(workerStage, t, ReasonCancelled)
+	case errors.As(err, &se):
+		w.ReportError(workerStage, t.BaseURL, err)
+		w.NotifyDiscard(workerStage, t, ReasonBadStatus)
+	case errors.As(err, &re):
+		if re.Err != nil {
+			w.ReportError(workerStage, t.BaseURL, err)
+		}
+		w.NotifyDiscard(workerStage, t, re.Reason)
+	default:
+		w.ReportError(workerStage, t.BaseURL, err)
+		w.NotifyDiscard(workerStage, t, ReasonFetchFailed)
+	}
+}
+
+// tryWait calls `Politeness.TryWait` of `w.politeness` for the host of `t`. It
+// returns a no-op function when the URL of `t` cannot be parsed, leaving the
+// error to `w.Fetch`.
+func (w *Worker) tryWait(t *domain.Target) (func(), time.Duration) {
+	u, err := url.Parse(t.BaseURL)
+	if err != nil {
+		return func() {}, 0
+	}
+	return w.politeness.TryWait(u.Host)
+}
+
 // Pipe connects `in` and `out` together. Any `*domain.Target` received from
 // `in` will be fetched and the web page content will be sent to `out` if no
-// error occurs.
+// error occurs. Otherwise, the error is reported as a `*internal.CrawlError`,
+// including when the web page is answered with a rejected status code.
+//
+// NOTE: Web pages that can be fetched again according to the
+// `internal.RetryPolicy` of `w` are queued until their retry delay is over,
+// without blocking any goroutine. Once their attempts are exhausted, the error
+// is reported as a `*internal.RetryError`.
+//
+// NOTE: At most `w.concurrency` web pages are fetched at the same time, see
+// `internal.ForEach`. Web pages that cannot be fetched yet because of
+// `w.politeness` are queued as well until their host is ready, so that they do
+// not hold a slot other hosts could use.
 //
 // NOTE: This function will loop over a channel until `in` is closed. After that
-// it will close `out`.
-func (w *Worker) Pipe(wg *sync.WaitGroup, in <-chan *domain.Target, out chan<- *domain.Target) {
+// it will close `out` once every web page has been fetched.
+func (w *Worker) Pipe(ctx context.Context, wg *sync.WaitGroup, in <-chan *domain.Target, out chan<- *domain.Target) {
 	defer close(out)
 
-	for t := range in {
-		wg.Add(1)
-		go func(tgt *domain.Target) {
-			if err := w.Fetch(tgt); err != nil {
-				log.Printf("Worker: %f", err)
-				wg.Done()
-			} else {
-				out <- tgt
+	q := newRetryQueue()
+	queued := make(chan *domain.Target)
+	go q.run(ctx, in, queued)
+
+	// deferred holds the `*domain.Target`s queued because of `w.politeness`.
+	deferred := sync.Map{}
+
+	ForEach(w.concurrency, queued, func(t *domain.Target) {
+		if _, ok := deferred.LoadAndDelete(t); !ok && t.Attempts == 0 {
+			w.NotifyEnter(workerStage, t)
+		}
+
+		fetchCtx := ctx
+		if w.politeness != nil && ctx.Err() == nil {
+			release, wait := w.tryWait(t)
+			if wait > 0 {
+				deferred.Store(t, true)
+				q.retry(t, wait)
+				return
 			}
+			defer release()
+			fetchCtx = withPaced(ctx)
+		}
+
+		if ctx.Err() != nil {
+			w.NotifyDiscard(workerStage, t, ReasonCancelled)
 			wg.Done()
-		}(t)
-	}
+		} else if err := w.Fetch(fetchCtx, t); err != nil {
+			t.Attempts++
+			if !w.retryable(ctx, err) {
+				w.discard(ctx, wg, t, err)
+			} else if t.Attempts < w.retry.MaxAttempts {
+				q.retry(t, w.retry.delay(t.Attempts, t.Header))
+				return
+			} else if t.Attempts > 1 {
+				w.discard(ctx, wg, t, &RetryError{Attempts: t.Attempts, Err: err})
+			} else {
+				w.discard(ctx, wg, t, err)
+			}
+		} else {
+			t.Attempts++
+			w.NotifyLeave(workerStage, t)
+			out <- t
+		}
+		q.done()
+	})
 }
@@ -1,15 +1,22 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/url"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/timtosi/mcrawler/internal/cache"
 	"github.com/timtosi/mcrawler/internal/domain"
 )
 
@@ -23,8 +30,12 @@ func mockServer() *httptest.Server {
 			case "/good":
 				w.WriteHeader(http.StatusOK)
 				w.Write([]byte(`correctly retrieved`))
+			case "/gone":
+				w.WriteHeader(http.StatusGone)
+				w.Write([]byte(`gone`))
 			default:
 				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`not found`))
 			}
 		}),
 	)
@@ -43,6 +54,7 @@ func TestWorker_NewWorker(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			assert.NotPanics(t, func() { NewWorker() })
+			assert.Equal(t, DefaultConcurrency, NewWorker().concurrency)
 		})
 	}
 }
@@ -64,7 +76,7 @@ func TestWorker_Fetch(t *testing.T) {
 			"pathNotFound",
 			"/nope",
 			"",
-			assert.Nil,
+			assert.NotNil,
 		},
 		{
 			"badURL",
@@ -82,12 +94,48 @@ func TestWorker_Fetch(t *testing.T) {
 			w := NewWorker()
 			tgt := domain.NewTarget(fmt.Sprintf("%s%s", mockServer.URL, tc.mockURL))
 
-			tc.expectedAssertFunc(t, w.Fetch(tgt))
+			tc.expectedAssertFunc(t, w.Fetch(context.Background(), tgt))
 			assert.Equal(t, tc.expectedContent, string(tgt.Content))
 		})
 	}
 }
 
+func TestWorker_WithBudget(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mockBudget  *Budget
+		mockFetches int
+		mockRetry   bool
+		expectedErr error
+	}{
+		{"underPageLimit", NewBudget(WithMaxPages(2)), 2, false, nil},
+		{"overPageLimit", NewBudget(WithMaxPages(2)), 3, false, ErrMaxPages},
+		{"overByteLimit", NewBudget(WithMaxBytes(30)), 2, false, ErrMaxBytes},
+		{"retriesCountOnce", NewBudget(WithMaxPages(1)), 3, true, nil},
+	}
+
+	ms := mockServer()
+	defer ms.Close()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var err error
+			w := NewWorker(WithBudget(tc.mockBudget))
+
+			tgt := domain.NewTarget(ms.URL + "/good")
+			for i := 0; i < tc.mockFetches; i++ {
+				if !tc.mockRetry {
+					tgt = domain.NewTarget(ms.URL + "/good")
+				}
+				err = w.Fetch(context.Background(), tgt)
+				tgt.Attempts++
+			}
+			assert.Equal(t, tc.expectedErr == nil, err == nil)
+			assert.Equal(t, tc.expectedErr, tc.mockBudget.Err())
+		})
+	}
+}
+
 func TestWorker_Pipe(t *testing.T) {
 	testCases := []struct {
 		name            string
@@ -105,7 +153,7 @@ func TestWorker_Pipe(t *testing.T) {
 			"pathNotFound",
 			"/nope",
 			"",
-			false,
+			true,
 		},
 		{
 			"badURL",
@@ -129,7 +177,7 @@ func TestWorker_Pipe(t *testing.T) {
 			wg := sync.WaitGroup{}
 			wg.Add(1)
 
-			go w.Pipe(&wg, inChan, outChan)
+			go w.Pipe(context.Background(), &wg, inChan, outChan)
 			inChan <- tgt
 
 			select {
@@ -143,3 +191,850 @@ func TestWorker_Pipe(t *testing.T) {
 		})
 	}
 }
+
+func TestWorker_WithConcurrency(t *testing.T) {
+	testCases := []struct {
+		name            string
+		mockConcurrency int
+		expectedMax     int32
+	}{
+		{"sequential", 1, 1},
+		{"bounded", 3, 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var running, max int32
+			ms := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					n := atomic.AddInt32(&running, 1)
+					for m := atomic.LoadInt32(&max); n > m; m = atomic.LoadInt32(&max) {
+						if atomic.CompareAndSwapInt32(&max, m, n) {
+							break
+						}
+					}
+					time.Sleep(10 * time.Millisecond)
+					atomic.AddInt32(&running, -1)
+				}),
+			)
+			defer ms.Close()
+
+			w := NewWorker(WithConcurrency(tc.mockConcurrency))
+			inChan := make(chan *domain.Target)
+			outChan := make(chan *domain.Target)
+			wg := sync.WaitGroup{}
+			wg.Add(10)
+
+			go w.Pipe(context.Background(), &wg, inChan, outChan)
+			go func() {
+				for i := 0; i < 10; i++ {
+					inChan <- domain.NewTarget(ms.URL)
+				}
+				close(inChan)
+			}()
+
+			for range outChan {
+				wg.Done()
+			}
+			wg.Wait()
+			assert.Equal(t, tc.expectedMax, max)
+		})
+	}
+}
+
+func TestWorker_WithPoliteness(t *testing.T) {
+	ms := mockServer()
+	defer ms.Close()
+
+	w := NewWorker(WithPoliteness(NewPoliteness(HostPolicy{Delay: 100 * time.Millisecond})))
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, w.Fetch(context.Background(), domain.NewTarget(ms.URL+"/good")))
+	}
+	assert.True(t, time.Since(start) >= 200*time.Millisecond)
+}
+
+func TestWorker_WithPoliteness_pipe(t *testing.T) {
+	slow := mockServer()
+	defer slow.Close()
+	fast := mockServer()
+	defer fast.Close()
+
+	p := NewPoliteness(HostPolicy{}, WithHostPolicy(slow.Listener.Addr().String(), HostPolicy{Delay: time.Hour}))
+	w := NewWorker(WithPoliteness(p), WithConcurrency(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	inChan := make(chan *domain.Target)
+	outChan := make(chan *domain.Target)
+	wg := sync.WaitGroup{}
+	wg.Add(3)
+	go w.Pipe(ctx, &wg, inChan, outChan)
+
+	inChan <- domain.NewTarget(slow.URL + "/good")
+	res := <-outChan
+	assert.Equal(t, slow.URL+"/good", res.BaseURL)
+	wg.Done()
+
+	inChan <- domain.NewTarget(slow.URL + "/other")
+	inChan <- domain.NewTarget(fast.URL + "/good")
+	select {
+	case res := <-outChan:
+		assert.Equal(t, fast.URL+"/good", res.BaseURL)
+		wg.Done()
+	case <-time.After(time.Second):
+		t.Fatalf("TestWorker_WithPoliteness_pipe: slot held by a slow host")
+	}
+
+	cancel()
+	close(inChan)
+	_, ok := <-outChan
+	assert.False(t, ok)
+	wg.Wait()
+}
+
+func TestWorker_WithPoliteness_redirect(t *testing.T) {
+	ms := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/moved" {
+				http.Redirect(w, r, "/good", http.StatusMovedPermanently)
+				return
+			}
+			w.Write([]byte("correctly retrieved"))
+		}),
+	)
+	defer ms.Close()
+
+	w := NewWorker(WithPoliteness(NewPoliteness(HostPolicy{Delay: 100 * time.Millisecond})))
+
+	start := time.Now()
+	assert.Nil(t, w.Fetch(context.Background(), domain.NewTarget(ms.URL+"/moved")))
+	assert.True(t, time.Since(start) >= 100*time.Millisecond)
+}
+
+// peakGoroutines is an helper function only used for test purposes. It
+// samples the number of goroutines until `stop` is closed, then sends the
+// highest value seen to the returned channel.
+func peakGoroutines(stop <-chan struct{}) <-chan int {
+	peak := make(chan int, 1)
+	go func() {
+		max := runtime.NumGoroutine()
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if n := runtime.NumGoroutine(); n > max {
+					max = n
+				}
+			case <-stop:
+				peak <- max
+				return
+			}
+		}
+	}()
+	return peak
+}
+
+func BenchmarkWorker_Pipe(b *testing.B) {
+	benchCases := []struct {
+		name            string
+		mockConcurrency int
+	}{
+		{"unbounded", 0},
+		{"concurrency8", 8},
+	}
+
+	ms := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(time.Millisecond)
+			w.Write([]byte(`correctly retrieved`))
+		}),
+	)
+	defer ms.Close()
+
+	for _, bc := range benchCases {
+		b.Run(bc.name, func(b *testing.B) {
+			w := NewWorker(WithConcurrency(bc.mockConcurrency))
+			inChan := make(chan *domain.Target)
+			outChan := make(chan *domain.Target)
+			wg := sync.WaitGroup{}
+			wg.Add(b.N)
+
+			stop := make(chan struct{})
+			peak := peakGoroutines(stop)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			go w.Pipe(context.Background(), &wg, inChan, outChan)
+			go func() {
+				for i := 0; i < b.N; i++ {
+					inChan <- domain.NewTarget(ms.URL)
+				}
+				close(inChan)
+			}()
+			for range outChan {
+				wg.Done()
+			}
+			wg.Wait()
+			b.StopTimer()
+
+			close(stop)
+			b.ReportMetric(float64(<-peak), "goroutines")
+		})
+	}
+}
+
+func TestWorker_WithCache(t *testing.T) {
+	var version int32
+	ms := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/etag":
+				w.Header().Set("ETag", `"v1"`)
+				if r.Header.Get("If-None-Match") == `"v1"` {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+			case "/last-modified":
+				w.Header().Set("Last-Modified", "Thu, 14 Mar 2019 15:09:26 GMT")
+				if r.Header.Get("If-Modified-Since") == "Thu, 14 Mar 2019 15:09:26 GMT" {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+			case "/moved":
+				http.Redirect(w, r, "/etag", http.StatusMovedPermanently)
+				return
+			case "/changing":
+				v := atomic.AddInt32(&version, 1)
+				w.Header().Set("ETag", fmt.Sprintf(`"v%d"`, v))
+				fmt.Fprintf(w, "version %d", v)
+				return
+			}
+			w.Write([]byte("content of " + r.URL.Path))
+		}),
+	)
+	defer ms.Close()
+
+	testCases := []struct {
+		name              string
+		mockPath          string
+		expectedStatus    int
+		expectedContent   string
+		expectedUnchanged bool
+	}{
+		{"etag", "/etag", http.StatusNotModified, "content of /etag", true},
+		{"lastModified", "/last-modified", http.StatusNotModified, "content of /last-modified", true},
+		{"noValidator", "/none", http.StatusOK, "content of /none", true},
+		{"redirect", "/moved", http.StatusNotModified, "content of /etag", true},
+		{"changing", "/changing", http.StatusOK, "version 2", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := cache.NewCache()
+			w := NewWorker(WithCache(c))
+
+			first := domain.NewTarget(ms.URL + tc.mockPath)
+			assert.Nil(t, w.Fetch(context.Background(), first))
+			assert.False(t, first.Unchanged)
+
+			second := domain.NewTarget(ms.URL + tc.mockPath)
+			assert.Nil(t, w.Fetch(context.Background(), second))
+			assert.Equal(t, tc.expectedStatus, second.StatusCode)
+			assert.Equal(t, tc.expectedContent, string(second.Content))
+			assert.Equal(t, tc.expectedUnchanged, second.Unchanged)
+			assert.Equal(t, !tc.expectedUnchanged, len(c.Changed(true)) == 1)
+
+			_, ok := c.LastModified(second.FinalURL())
+			assert.True(t, ok)
+		})
+	}
+}
+
+func TestWorker_WithStatusPolicy(t *testing.T) {
+	gone, err := ParseStatusPolicy("2xx,410")
+	if err != nil {
+		t.Fatalf("TestWorker_WithStatusPolicy: %v", err)
+	}
+
+	testCases := []struct {
+		name            string
+		mockPolicy      StatusPolicy
+		mockURL         string
+		expectedStatus  int
+		expectedContent string
+		expectedErr     error
+	}{
+		{"default", DefaultStatusPolicy, "/good", http.StatusOK, "correctly retrieved", nil},
+		{"defaultNotFound", DefaultStatusPolicy, "/nope", http.StatusNotFound, "", &StatusError{Code: http.StatusNotFound}},
+		{"defaultGone", DefaultStatusPolicy, "/gone", http.StatusGone, "", &StatusError{Code: http.StatusGone}},
+		{"customGone", gone, "/gone", http.StatusGone, "gone", nil},
+		{"customNotFound", gone, "/nope", http.StatusNotFound, "", &StatusError{Code: http.StatusNotFound}},
+	}
+
+	ms := mockServer()
+	defer ms.Close()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := NewWorker(WithStatusPolicy(tc.mockPolicy))
+			tgt := domain.NewTarget(ms.URL + tc.mockURL)
+
+			assert.Equal(t, tc.expectedErr, w.Fetch(context.Background(), tgt))
+			assert.Equal(t, tc.expectedStatus, tgt.StatusCode)
+			assert.NotEmpty(t, tgt.Header.Get("Content-Length"))
+			assert.Equal(t, tc.expectedContent, string(tgt.Content))
+		})
+	}
+}
+
+func TestWorker_Redirects(t *testing.T) {
+	ms := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/a":
+				http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+			case "/b":
+				http.Redirect(w, r, "/c", http.StatusFound)
+			case "/c":
+				w.Write([]byte("final"))
+			case "/loop":
+				http.Redirect(w, r, "/loop-back", http.StatusFound)
+			case "/loop-back":
+				http.Redirect(w, r, "/loop", http.StatusFound)
+			case "/off":
+				http.Redirect(w, r, "http://offhost.invalid/", http.StatusFound)
+			}
+		}),
+	)
+	defer ms.Close()
+
+	f, err := NewFollower(ms.URL)
+	if err != nil {
+		t.Fatalf("TestWorker_Redirects: %v", err)
+	}
+
+	testCases := []struct {
+		name              string
+		mockPath          string
+		mockSeen          []string
+		mockMaxRedirects  int
+		expectedContent   string
+		expectedChain     []domain.Redirect
+		expectedReason    string
+		expectedErr       error
+		expectedSeenAfter []string
+	}{
+		{
+			"followed",
+			"/a",
+			nil,
+			10,
+			"final",
+			[]domain.Redirect{
+				{URL: ms.URL + "/a", StatusCode: http.StatusMovedPermanently, Location: ms.URL + "/b"},
+				{URL: ms.URL + "/b", StatusCode: http.StatusFound, Location: ms.URL + "/c"},
+			},
+			"",
+			nil,
+			[]string{ms.URL + "/b", ms.URL + "/c"},
+		},
+		{
+			"noRedirect",
+			"/c",
+			nil,
+			10,
+			"final",
+			nil,
+			"",
+			nil,
+			nil,
+		},
+		{
+			"tooMany",
+			"/a",
+			nil,
+			1,
+			"",
+			[]domain.Redirect{
+				{URL: ms.URL + "/a", StatusCode: http.StatusMovedPermanently, Location: ms.URL + "/b"},
+				{URL: ms.URL + "/b", StatusCode: http.StatusFound, Location: ms.URL + "/c"},
+			},
+			ReasonBadRedirect,
+			ErrTooManyRedirects,
+			[]string{ms.URL + "/b"},
+		},
+		{
+			"loop",
+			"/loop",
+			nil,
+			10,
+			"",
+			[]domain.Redirect{
+				{URL: ms.URL + "/loop", StatusCode: http.StatusFound, Location: ms.URL + "/loop-back"},
+				{URL: ms.URL + "/loop-back", StatusCode: http.StatusFound, Location: ms.URL + "/loop"},
+			},
+			ReasonBadRedirect,
+			ErrRedirectLoop,
+			[]string{ms.URL + "/loop-back"},
+		},
+		{
+			"offHost",
+			"/off",
+			nil,
+			10,
+			"",
+			[]domain.Redirect{
+				{URL: ms.URL + "/off", StatusCode: http.StatusFound, Location: "http://offhost.invalid/"},
+			},
+			ReasonOffHost,
+			nil,
+			nil,
+		},
+		{
+			"alreadySeen",
+			"/b",
+			[]string{ms.URL + "/c"},
+			10,
+			"",
+			[]domain.Redirect{
+				{URL: ms.URL + "/b", StatusCode: http.StatusFound, Location: ms.URL + "/c"},
+			},
+			ReasonAlreadySeen,
+			nil,
+			[]string{ms.URL + "/c"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := NewArchiver()
+			a.Restore(tc.mockSeen)
+			w := NewWorker(WithMaxRedirects(tc.mockMaxRedirects), WithRedirectCheckers(f, a))
+			tgt := domain.NewTarget(ms.URL + tc.mockPath)
+
+			err := w.Fetch(context.Background(), tgt)
+			if len(tc.expectedReason) == 0 {
+				assert.Nil(t, err)
+			} else if assert.IsType(t, &RedirectError{}, err) {
+				assert.Equal(t, tc.expectedReason, err.(*RedirectError).Reason)
+				assert.Equal(t, tc.expectedErr, err.(*RedirectError).Err)
+			}
+			assert.Equal(t, tc.expectedContent, string(tgt.Content))
+			assert.Equal(t, tc.expectedChain, tgt.Redirects)
+			assert.ElementsMatch(t, tc.expectedSeenAfter, a.Seen())
+		})
+	}
+}
+
+func TestWorker_WithRetry(t *testing.T) {
+	testCases := []struct {
+		name             string
+		mockPolicy       RetryPolicy
+		mockFailures     int
+		mockStatus       int
+		mockRetryAfter   string
+		expectedContent  string
+		expectedAttempts int
+		expectedErr      error
+	}{
+		{
+			"noRetry",
+			RetryPolicy{},
+			1,
+			http.StatusServiceUnavailable,
+			"",
+			"",
+			1,
+			&StatusError{Code: http.StatusServiceUnavailable},
+		},
+		{
+			"recovered",
+			RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond},
+			2,
+			http.StatusServiceUnavailable,
+			"",
+			"recovered",
+			3,
+			nil,
+		},
+		{
+			"retryAfter",
+			RetryPolicy{MaxAttempts: 2, BaseDelay: time.Hour},
+			1,
+			http.StatusTooManyRequests,
+			"0",
+			"recovered",
+			2,
+			nil,
+		},
+		{
+			"exhausted",
+			RetryPolicy{MaxAttempts: 2, BaseDelay: 10 * time.Millisecond},
+			5,
+			http.StatusBadGateway,
+			"",
+			"",
+			2,
+			&RetryError{Attempts: 2, Err: &StatusError{Code: http.StatusBadGateway}},
+		},
+		{
+			"notRetryable",
+			RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond},
+			5,
+			http.StatusNotFound,
+			"",
+			"",
+			1,
+			&StatusError{Code: http.StatusNotFound},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var hits int32
+			ms := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if atomic.AddInt32(&hits, 1) <= int32(tc.mockFailures) {
+						if len(tc.mockRetryAfter) != 0 {
+							w.Header().Set("Retry-After", tc.mockRetryAfter)
+						}
+						w.WriteHeader(tc.mockStatus)
+						return
+					}
+					w.Write([]byte("recovered"))
+				}),
+			)
+			defer ms.Close()
+
+			sink := &mockSink{}
+			w := NewWorker(WithRetry(tc.mockPolicy))
+			w.SetErrorSink(sink)
+			tgt := domain.NewTarget(ms.URL)
+
+			res := runPipe(t, w, tgt)
+			assert.Equal(t, tc.expectedAttempts, tgt.Attempts)
+			assert.Equal(t, int32(tc.expectedAttempts), atomic.LoadInt32(&hits))
+			if tc.expectedErr != nil {
+				assert.Empty(t, res)
+				if assert.Len(t, sink.errs, 1) {
+					assert.Equal(t, tc.expectedErr, sink.errs[0].Err)
+				}
+			} else if assert.Len(t, res, 1) {
+				assert.Equal(t, tc.expectedContent, string(res[0].Content))
+				assert.Empty(t, sink.errs)
+			}
+		})
+	}
+}
+
+func TestWorker_ContentLimits(t *testing.T) {
+	var methods []string
+	var mu sync.Mutex
+	ms := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			methods = append(methods, r.Method)
+			mu.Unlock()
+
+			switch r.URL.Path {
+			case "/logo.png":
+				w.Header().Set("Content-Type", "image/png")
+				w.Write([]byte("\x89PNG"))
+			case "/missing.png":
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				w.Header().Set("Content-Type", "text/html")
+				w.Write([]byte("<html></html>"))
+			}
+		}),
+	)
+	defer ms.Close()
+
+	testCases := []struct {
+		name              string
+		mockOpts          []func(*Worker)
+		mockPath          string
+		expectedMethod    string
+		expectedContent   string
+		expectedSkipped   bool
+		expectedTruncated bool
+		expectedErr       error
+	}{
+		{"default", nil, "/logo.png", http.MethodGet, "\x89PNG", false, false, nil},
+		{"allowed", []func(*Worker){WithContentTypes(HTMLContentTypes...)}, "/", http.MethodGet, "<html></html>", false, false, nil},
+		{"rejectedType", []func(*Worker){WithContentTypes(HTMLContentTypes...)}, "/logo.png", http.MethodGet, "", true, false, nil},
+		{"truncated", []func(*Worker){WithMaxBodySize(6)}, "/", http.MethodGet, "<html>", false, true, nil},
+		{"head", []func(*Worker){WithHeadNonHTML()}, "/logo.png", http.MethodHead, "", true, false, nil},
+		{"headHTML", []func(*Worker){WithHeadNonHTML()}, "/", http.MethodGet, "<html></html>", false, false, nil},
+		{"headNotFound", []func(*Worker){WithHeadNonHTML()}, "/missing.png", http.MethodHead, "", false, false, &StatusError{Code: http.StatusNotFound}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mu.Lock()
+			methods = nil
+			mu.Unlock()
+
+			w := NewWorker(tc.mockOpts...)
+			tgt := domain.NewTarget(ms.URL + tc.mockPath)
+
+			assert.Equal(t, tc.expectedErr, w.Fetch(context.Background(), tgt))
+			assert.Equal(t, []string{tc.expectedMethod}, methods)
+			assert.Equal(t, tc.expectedContent, string(tgt.Content))
+			assert.Equal(t, tc.expectedSkipped, tgt.Skipped)
+			assert.Equal(t, tc.expectedTruncated, tgt.Truncated)
+			assert.NotZero(t, tgt.StatusCode)
+		})
+	}
+}
+
+func TestWorker_WithAssetCheck(t *testing.T) {
+	var requests []string
+	var mu sync.Mutex
+	ms := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			requests = append(requests, r.Method+" "+r.Header.Get("Range"))
+			mu.Unlock()
+
+			switch r.URL.Path {
+			case "/logo.png":
+				w.Header().Set("Content-Type", "image/png")
+				w.Write([]byte("\x89PNG"))
+			case "/nohead.zip":
+				if r.Method == http.MethodHead {
+					w.WriteHeader(http.StatusMethodNotAllowed)
+					return
+				}
+				w.Header().Set("Content-Type", "application/zip")
+				w.Header().Set("Content-Range", "bytes 0-0/4")
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write([]byte("P"))
+			case "/download":
+				w.Header().Set("Content-Type", "application/pdf")
+				w.Write([]byte("%PDF"))
+			case "/":
+				w.Header().Set("Content-Type", "text/html")
+				w.Write([]byte("<html></html>"))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}),
+	)
+	defer ms.Close()
+
+	testCases := []struct {
+		name             string
+		mockPath         string
+		expectedRequests []string
+		expectedStatus   int
+		expectedContent  string
+		expectedAsset    bool
+		expectedErr      error
+	}{
+		{"page", "/", []string{"GET "}, http.StatusOK, "<html></html>", false, nil},
+		{"head", "/logo.png", []string{"HEAD "}, http.StatusOK, "", true, nil},
+		{"rangedGet", "/nohead.zip", []string{"HEAD ", "GET bytes=0-0"}, http.StatusPartialContent, "", true, nil},
+		{"contentType", "/download", []string{"GET "}, http.StatusOK, "", true, nil},
+		{"broken", "/missing.png", []string{"HEAD "}, http.StatusNotFound, "", true, &StatusError{Code: http.StatusNotFound}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mu.Lock()
+			requests = nil
+			mu.Unlock()
+
+			w := NewWorker(WithAssetCheck())
+			tgt := domain.NewTarget(ms.URL + tc.mockPath)
+
+			assert.Equal(t, tc.expectedErr, w.Fetch(context.Background(), tgt))
+			assert.Equal(t, tc.expectedRequests, requests)
+			assert.Equal(t, tc.expectedStatus, tgt.StatusCode)
+			assert.Equal(t, tc.expectedContent, string(tgt.Content))
+			assert.Equal(t, tc.expectedAsset, tgt.Asset)
+		})
+	}
+
+	ok, err := ParseStatusPolicy("200")
+	if err != nil {
+		t.Fatalf("TestWorker_WithAssetCheck: %v", err)
+	}
+	tgt := domain.NewTarget(ms.URL + "/nohead.zip")
+	assert.Nil(t, NewWorker(WithAssetCheck(), WithStatusPolicy(ok)).Fetch(context.Background(), tgt))
+	assert.Equal(t, http.StatusPartialContent, tgt.StatusCode)
+}
+
+func TestWorker_Identity(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]http.Header)
+	record := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			received[name] = r.Header.Clone()
+			mu.Unlock()
+
+			if c, err := r.Cookie("session"); err != nil || c.Value != "1" {
+				http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+			}
+			if to := r.URL.Query().Get("to"); len(to) != 0 {
+				http.Redirect(w, r, to, http.StatusFound)
+			}
+		})
+	}
+	staging := httptest.NewServer(record("staging"))
+	defer staging.Close()
+	other := httptest.NewServer(record("other"))
+	defer other.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("TestWorker_Identity: %v", err)
+	}
+	u, err := url.Parse(staging.URL)
+	if err != nil {
+		t.Fatalf("TestWorker_Identity: %v", err)
+	}
+
+	testCases := []struct {
+		name             string
+		mockOpts         []func(*Worker)
+		mockURL          string
+		expectedStaging  http.Header
+		expectedOther    http.Header
+		expectedStagingA string
+	}{
+		{
+			"userAgentAndHeaders",
+			[]func(*Worker){
+				WithUserAgent("mcrawler-test"),
+				WithHeader("X-Env", "prod"),
+				WithHostHeader(u.Host, "X-Env", "staging"),
+			},
+			staging.URL + "/?to=" + url.QueryEscape(other.URL),
+			http.Header{"User-Agent": {"mcrawler-test"}, "X-Env": {"staging"}},
+			http.Header{"User-Agent": {"mcrawler-test"}, "X-Env": {"prod"}},
+			"",
+		},
+		{
+			"basicAuthScoped",
+			[]func(*Worker){WithBasicAuth(u.Host, "user", "secret")},
+			staging.URL + "/?to=" + url.QueryEscape(other.URL),
+			nil,
+			http.Header{"Authorization": nil},
+			"Basic dXNlcjpzZWNyZXQ=",
+		},
+		{
+			"bearerTokenHostname",
+			[]func(*Worker){WithBearerToken(u.Hostname(), "token")},
+			staging.URL,
+			nil,
+			nil,
+			"Bearer token",
+		},
+		{
+			"bearerTokenNotSentOnRedirect",
+			[]func(*Worker){WithBearerToken(u.Host, "token")},
+			other.URL + "/?to=" + url.QueryEscape(staging.URL+"/?to="+url.QueryEscape(other.URL)),
+			nil,
+			http.Header{"Authorization": nil},
+			"Bearer token",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mu.Lock()
+			received = make(map[string]http.Header)
+			mu.Unlock()
+
+			w := NewWorker(append(tc.mockOpts, WithStatusPolicy(func(int) bool { return true }))...)
+			assert.Nil(t, w.Fetch(context.Background(), domain.NewTarget(tc.mockURL)))
+
+			for key, values := range tc.expectedStaging {
+				assert.Equal(t, values, received["staging"][key], key)
+			}
+			for key, values := range tc.expectedOther {
+				assert.Equal(t, values, received["other"][key], key)
+			}
+			assert.Equal(t, tc.expectedStagingA, received["staging"].Get("Authorization"))
+		})
+	}
+
+	t.Run("cookieJar", func(t *testing.T) {
+		w := NewWorker(WithCookieJar(jar))
+		assert.Nil(t, w.Fetch(context.Background(), domain.NewTarget(staging.URL)))
+		assert.Empty(t, received["staging"].Get("Cookie"))
+		assert.Nil(t, w.Fetch(context.Background(), domain.NewTarget(staging.URL)))
+		assert.Equal(t, "session=1", received["staging"].Get("Cookie"))
+	})
+}
+
+// failingBody is a `io.ReadCloser` only used for test purposes. It fails
+// every read and records whether it has been closed.
+type failingBody struct{ closed bool }
+
+// Read implements the `io.Reader` interface.
+func (b *failingBody) Read([]byte) (int, error) { return 0, errors.New("connection reset") }
+
+// Close implements the `io.Closer` interface.
+func (b *failingBody) Close() error { b.closed = true; return nil }
+
+// roundTripFunc is a named type only used for test purposes. It implements the
+// `http.RoundTripper` interface.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements the `http.RoundTripper` interface.
+func (fn roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return fn(req) }
+
+func TestWorker_Fetch_closesBody(t *testing.T) {
+	body := &failingBody{}
+	w := NewWorker(WithMaxBodySize(10))
+	w.identity.base = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: body, Request: req}, nil
+	})
+
+	assert.NotNil(t, w.Fetch(context.Background(), domain.NewTarget("http://a.com")))
+	assert.True(t, body.closed)
+}
+
+func TestWorker_WithRetry_redirect(t *testing.T) {
+	var hits int32
+	ms := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/a":
+				http.Redirect(w, r, "/b", http.StatusFound)
+			case "/b":
+				if atomic.AddInt32(&hits, 1) == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Write([]byte("recovered"))
+			}
+		}),
+	)
+	defer ms.Close()
+
+	a := NewArchiver()
+	sink := &mockSink{}
+	w := NewWorker(
+		WithRedirectCheckers(a),
+		WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond}),
+	)
+	w.SetErrorSink(sink)
+	tgt := domain.NewTarget(ms.URL + "/a")
+
+	res := runPipe(t, w, tgt)
+	assert.Equal(t, 2, tgt.Attempts)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	assert.Empty(t, sink.errs)
+	if assert.Len(t, res, 1) {
+		assert.Equal(t, "recovered", string(res[0].Content))
+		assert.Equal(t, ms.URL+"/b", res[0].FinalURL())
+	}
+	assert.True(t, a.IsAlreadySeen(ms.URL+"/b"))
+}